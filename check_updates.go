@@ -2,23 +2,42 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
 	"os/exec"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
+
+	"github.com/Evren-os/GoferShell/internal/term"
+	"github.com/Evren-os/GoferShell/internal/version"
 )
 
 const (
-	colorRed    = "\033[31m"
-	colorGreen  = "\033[32m"
-	colorYellow = "\033[33m"
-	colorCyan   = "\033[36m"
-	colorReset  = "\033[0m"
-
 	commandTimeout = 30 * time.Second
+	// AUR helpers query the AUR over the network and are often much slower
+	defaultAURTimeout = 120 * time.Second
+
+	// Exit codes for --quiet-exit
+	exitOfficialPending = 10
+	exitAURPending      = 11
+	exitBothPending     = 12
+
+	sizeLookupWorkers = 8
+
+	archNewsURL     = "https://archlinux.org/feeds/news/"
+	archNewsTimeout = 10 * time.Second
+	archNewsItems   = 3
 )
 
 type updateResult struct {
@@ -26,76 +45,392 @@ type updateResult struct {
 	err    error
 }
 
+// UpdateSource is somewhere pending updates come from. Fetch returns one
+// "name old -> new" line per update, or "" when everything is current, and
+// stops early when ctx is cancelled.
+type UpdateSource interface {
+	Name() string
+	Fetch(ctx context.Context) (string, error)
+}
+
+// sourceSet holds the sources picked for this platform, one per output section
+type sourceSet struct {
+	official UpdateSource // the distribution's package manager
+	aur      UpdateSource // user repository; nil when the platform has none
+	flatpak  UpdateSource // nil when flatpak isn't installed
+}
+
+type pacmanSource struct{}
+
+func (pacmanSource) Name() string                              { return "official" }
+func (pacmanSource) Fetch(ctx context.Context) (string, error) { return fetchOfficialUpdates(ctx) }
+
+type aurSource struct {
+	helper  string
+	timeout time.Duration
+}
+
+func (aurSource) Name() string { return "AUR" }
+func (s aurSource) Fetch(ctx context.Context) (string, error) {
+	return fetchAURUpdates(ctx, s.helper, s.timeout)
+}
+
+type flatpakSource struct{}
+
+func (flatpakSource) Name() string                              { return "flatpak" }
+func (flatpakSource) Fetch(ctx context.Context) (string, error) { return fetchFlatpakUpdates(ctx) }
+
+type packageUpdate struct {
+	Name       string `json:"name"`
+	OldVersion string `json:"oldVersion"`
+	NewVersion string `json:"newVersion"`
+}
+
+type displayOptions struct {
+	showFlatpak  bool
+	downloadSize int64           // negative when not computed
+	sizeUnknown  int             // packages whose download size couldn't be read
+	repoGroups   []repoGroup     // nil unless --group is active
+	newPackages  map[string]bool // nil unless --diff is active
+	columns      bool            // align name, old and new version
+	rebootNeeded []string        // pending packages from the reboot-sensitive list
+}
+
+type newsItem struct {
+	Title   string `xml:"title"`
+	PubDate string `xml:"pubDate"`
+}
+
+type newsFeed struct {
+	Items []newsItem `xml:"channel>item"`
+}
+
+// snapshot is the raw output of every source, cached between runs for --cache
+type snapshot struct {
+	CheckedAt  time.Time `json:"checkedAt"`
+	Official   string    `json:"official"`
+	AUR        string    `json:"aur"`
+	Flatpak    string    `json:"flatpak"`
+	HasFlatpak bool      `json:"hasFlatpak"`
+}
+
+// lastRun is the update set persisted between runs for --diff
+type lastRun struct {
+	CheckedAt time.Time       `json:"checkedAt"`
+	Updates   []packageUpdate `json:"updates"`
+}
+
+type repoGroup struct {
+	repo  string
+	lines []string
+}
+
+type jsonReport struct {
+	Official      []packageUpdate `json:"official"`
+	AUR           []packageUpdate `json:"aur"`
+	Flatpak       []packageUpdate `json:"flatpak,omitempty"`
+	OfficialCount int             `json:"officialCount"`
+	AURCount      int             `json:"aurCount"`
+	FlatpakCount  int             `json:"flatpakCount,omitempty"`
+	Total         int             `json:"total"`
+}
+
+// options holds the parsed command-line flags
+type options struct {
+	noVersion  bool
+	jsonOutput bool
+	countOnly  bool
+	format     string
+	refresh    bool
+	showSize   bool
+	notify     bool
+	group      bool
+	diff       bool
+	news       bool
+	quietExit  bool
+	aurHelper  string
+	sources    sourceSet
+	filters    stringList
+	columns    bool
+	cacheTTL   time.Duration
+	aurTimeout time.Duration
+}
+
+// stringList collects a repeatable string flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	if _, err := path.Match(value, ""); err != nil {
+		return fmt.Errorf("bad pattern %q: %w", value, err)
+	}
+	*l = append(*l, value)
+	return nil
+}
+
 func main() {
-	noVersion := flag.Bool("no-ver", false, "Strip version details from output")
+	var opts options
+	var watchSeconds int
+	var colorMode string
+	var showVersion bool
+	var cacheSeconds int
+	var aurTimeoutSeconds int
+	flag.BoolVar(&opts.noVersion, "no-ver", false, "Strip version details from output")
+	flag.BoolVar(&opts.jsonOutput, "json", false, "Print results as JSON instead of themed text")
+	flag.BoolVar(&opts.countOnly, "count", false, "Print only the total number of pending updates")
+	flag.StringVar(&opts.format, "format", "", "Print counts through a template, e.g. '{official}/{aur}' (tokens: {official}, {aur}, {flatpak}, {total})")
+	flag.StringVar(&opts.aurHelper, "helper", "", "Force a specific AUR helper (e.g. yay) instead of auto-detecting")
+	flag.BoolVar(&opts.refresh, "refresh", false, "Force a full re-sync of checkupdates' temporary database first (needs fakeroot)")
+	flag.BoolVar(&opts.showSize, "size", false, "Show the total download size of official updates")
+	flag.BoolVar(&opts.columns, "columns", false, "Align package names and old -> new versions in columns")
+	flag.BoolVar(&opts.group, "group", false, "Group official updates by repository")
+	flag.BoolVar(&opts.diff, "diff", false, "Highlight updates that are new since the previous run")
+	flag.BoolVar(&opts.news, "news", false, "Show the latest Arch Linux news above the update list")
+	flag.BoolVar(&opts.notify, "notify", false, "Send a desktop notification when updates are available")
+	flag.BoolVar(&opts.quietExit, "quiet-exit", false, "Print nothing; exit 10 (official), 11 (AUR) or 12 (both) when updates are pending")
+	flag.Var(&opts.filters, "filter", "Only show packages matching this glob (repeatable, e.g. -filter 'linux*')")
+	flag.IntVar(&watchSeconds, "watch", 0, "Re-run the check every N seconds until interrupted")
+	flag.StringVar(&colorMode, "color", term.ColorAuto, "Colorize output: auto, always or never")
+	flag.IntVar(&aurTimeoutSeconds, "aur-timeout", int(defaultAURTimeout.Seconds()), "Seconds to wait for the AUR helper before giving up")
+	flag.IntVar(&cacheSeconds, "cache", 0, "Reuse the last result if it is younger than N seconds instead of querying again")
+	flag.BoolVar(&showVersion, "version", false, "Print version information and exit")
 	flag.Parse()
 
-	// Verify required commands exist
-	if _, err := exec.LookPath("checkupdates"); err != nil {
-		fmt.Printf("%scheckupdates is MIA. Install 'pacman-contrib' or rot.%s\n", colorRed, colorReset)
+	if showVersion {
+		fmt.Println(version.String("check_updates"))
+		return
+	}
+
+	if err := term.SetColorMode(colorMode); err != nil {
+		fmt.Printf("%v\n", err)
 		os.Exit(1)
 	}
 
-	aurHelper := detectAURHelper()
-	if aurHelper == "" {
-		fmt.Printf("%sNo AUR helper found. Install paru or yay.%s\n", colorRed, colorReset)
+	if watchSeconds < 0 {
+		fmt.Printf("%s--watch needs a positive interval in seconds.%s\n", term.Red, term.Reset)
+		os.Exit(1)
+	}
+	if cacheSeconds < 0 {
+		fmt.Printf("%s--cache needs a positive number of seconds.%s\n", term.Red, term.Reset)
+		os.Exit(1)
+	}
+	opts.cacheTTL = time.Duration(cacheSeconds) * time.Second
+	if aurTimeoutSeconds < 1 {
+		fmt.Printf("%s--aur-timeout needs a positive number of seconds.%s\n", term.Red, term.Reset)
+		os.Exit(1)
+	}
+	opts.aurTimeout = time.Duration(aurTimeoutSeconds) * time.Second
+	if watchSeconds > 0 && opts.quietExit {
+		fmt.Printf("%s--watch and --quiet-exit don't mix.%s\n", term.Red, term.Reset)
 		os.Exit(1)
 	}
 
-	// Fetch updates concurrently
-	var wg sync.WaitGroup
-	officialChan := make(chan updateResult, 1)
-	aurChan := make(chan updateResult, 1)
-
-	wg.Add(2)
-	go func() {
-		defer wg.Done()
-		defer func() {
-			if r := recover(); r != nil {
-				officialChan <- updateResult{"", fmt.Errorf("panic recovered: %v", r)}
-			}
+	sources, err := detectSources(opts)
+	if err != nil {
+		fmt.Printf("%s%v%s\n", term.Red, err, term.Reset)
+		os.Exit(1)
+	}
+	opts.sources = sources
+
+	if watchSeconds > 0 {
+		watch(opts, time.Duration(watchSeconds)*time.Second)
+		return
+	}
+
+	code, err := runOnce(context.Background(), opts)
+	if err != nil {
+		fmt.Printf("%s%v%s\n", term.Red, err, term.Reset)
+		os.Exit(1)
+	}
+	os.Exit(code)
+}
+
+// watch re-runs the check on every tick until SIGINT/SIGTERM, which also
+// stops a check that is still running. The first run happens immediately.
+func watch(opts options, interval time.Duration) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if !opts.jsonOutput && !opts.countOnly && opts.format == "" {
+			fmt.Print(term.ClearScreen)
+			fmt.Printf("Last checked %s (every %s, Ctrl-C to quit)\n\n", time.Now().Format("15:04:05"), interval)
+		}
+		done := make(chan error, 1)
+		go func() {
+			_, err := runOnce(ctx, opts)
+			done <- err
 		}()
-		output, err := fetchOfficialUpdates()
-		officialChan <- updateResult{output, err}
-	}()
-
-	go func() {
-		defer wg.Done()
-		defer func() {
-			if r := recover(); r != nil {
-				aurChan <- updateResult{"", fmt.Errorf("panic recovered: %v", r)}
+		select {
+		case <-ctx.Done():
+			return
+		case err := <-done:
+			if err != nil && ctx.Err() == nil {
+				fmt.Printf("%s%v%s\n", term.Red, err, term.Reset)
 			}
-		}()
-		output, err := fetchAURUpdates(aurHelper)
-		aurChan <- updateResult{output, err}
-	}()
+		}
 
-	wg.Wait()
-	close(officialChan)
-	close(aurChan)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
 
-	officialResult := <-officialChan
-	aurResult := <-aurChan
+// runOnce fetches all sources and prints them in the requested format.
+// The returned code is the process exit status for --quiet-exit.
+func runOnce(ctx context.Context, opts options) (int, error) {
+	if opts.refresh {
+		if err := refreshSyncDB(); err != nil {
+			fmt.Fprintf(os.Stderr, "%sRefresh skipped: %v%s\n", term.Yellow, err, term.Reset)
+		}
+	}
 
-	// Handle errors - only report actual failures, not "no updates"
-	if officialResult.err != nil {
-		fmt.Printf("%sFailed to check official updates: %v%s\n", colorRed, officialResult.err, colorReset)
-		os.Exit(1)
+	snap, cached := loadSnapshot(opts.cacheTTL)
+	if !cached || opts.refresh {
+		var err error
+		if snap, err = fetchSnapshot(ctx, opts); err != nil {
+			return 1, err
+		}
+		if opts.cacheTTL > 0 {
+			if err := saveSnapshot(snap); err != nil {
+				fmt.Fprintf(os.Stderr, "%sCould not save result cache: %v%s\n", term.Yellow, err, term.Reset)
+			}
+		}
 	}
-	if aurResult.err != nil {
-		fmt.Printf("%sFailed to check AUR updates: %v%s\n", colorRed, aurResult.err, colorReset)
-		os.Exit(1)
+
+	hasFlatpak := snap.HasFlatpak
+	officialUpdates := snap.Official
+	aurUpdates := snap.AUR
+	flatpakUpdates := snap.Flatpak
+
+	ignored, err := loadIgnoreList()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sCould not read ignore list: %v%s\n", term.Yellow, err, term.Reset)
+	}
+	officialUpdates = dropIgnored(officialUpdates, ignored)
+	aurUpdates = dropIgnored(aurUpdates, ignored)
+
+	// Only --diff runs replace the previous run, and always with the unfiltered
+	// set, so polling with --count or a narrowed --filter doesn't skew the next diff.
+	var newPackages map[string]bool
+	if opts.diff {
+		var all []packageUpdate
+		for _, updates := range []string{officialUpdates, aurUpdates, flatpakUpdates} {
+			all = append(all, parseUpdates(updates)...)
+		}
+		previous, _ := loadLastRun()
+		if err := saveLastRun(all); err != nil {
+			fmt.Fprintf(os.Stderr, "%sCould not save update cache: %v%s\n", term.Yellow, err, term.Reset)
+		}
+		newPackages = newSince(previous, all)
+	}
+
+	if len(opts.filters) > 0 {
+		officialUpdates = filterUpdates(officialUpdates, opts.filters)
+		aurUpdates = filterUpdates(aurUpdates, opts.filters)
+		flatpakUpdates = filterUpdates(flatpakUpdates, opts.filters)
 	}
 
-	officialUpdates := officialResult.output
-	aurUpdates := aurResult.output
+	if opts.notify {
+		if err := sendNotification(countUpdates(officialUpdates), countUpdates(aurUpdates), countUpdates(flatpakUpdates)); err != nil {
+			fmt.Fprintf(os.Stderr, "%sNotification not sent: %v%s\n", term.Yellow, err, term.Reset)
+		}
+	}
+
+	if opts.quietExit {
+		return pendingExitCode(countUpdates(officialUpdates), countUpdates(aurUpdates)), nil
+	}
+
+	if opts.jsonOutput {
+		if err := printJSON(officialUpdates, aurUpdates, flatpakUpdates); err != nil {
+			return 1, fmt.Errorf("Failed to encode JSON: %v", err)
+		}
+		return 0, nil
+	}
+
+	if opts.countOnly {
+		fmt.Println(countUpdates(officialUpdates) + countUpdates(aurUpdates) + countUpdates(flatpakUpdates))
+		return 0, nil
+	}
+
+	if opts.format != "" {
+		fmt.Println(formatCounts(opts.format, countUpdates(officialUpdates), countUpdates(aurUpdates), countUpdates(flatpakUpdates)))
+		return 0, nil
+	}
+
+	display := displayOptions{showFlatpak: hasFlatpak, downloadSize: -1, columns: opts.columns}
+	rebootPatterns, err := loadRebootPackages()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sCould not read reboot list, using defaults: %v%s\n", term.Yellow, err, term.Reset)
+	}
+	display.rebootNeeded = rebootSensitive(rebootPatterns, officialUpdates, aurUpdates)
+	display.newPackages = newPackages
+	if opts.showSize && officialUpdates != "" {
+		display.downloadSize, display.sizeUnknown = fetchDownloadSize(parseUpdates(officialUpdates))
+	}
 
-	if *noVersion {
+	if opts.noVersion {
 		officialUpdates = stripVersions(officialUpdates)
 		aurUpdates = stripVersions(aurUpdates)
+		flatpakUpdates = stripVersions(flatpakUpdates)
+	}
+
+	if opts.group && officialUpdates != "" {
+		repos, order, err := fetchRepoMap()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%sCould not look up repositories, showing a flat list: %v%s\n", term.Yellow, err, term.Reset)
+		} else {
+			display.repoGroups = groupByRepo(officialUpdates, repos, order)
+		}
+	}
+
+	if opts.news {
+		items, err := fetchArchNews()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%sCouldn't reach Arch news: %v%s\n", term.Yellow, err, term.Reset)
+		} else {
+			displayNews(items)
+		}
 	}
 
-	displayResults(officialUpdates, aurUpdates)
+	displayResults(officialUpdates, aurUpdates, flatpakUpdates, display)
+	return 0, nil
+}
+
+// detectSources picks the update sources for this machine. Only Arch (pacman
+// plus an AUR helper) is supported so far; other package managers slot in here
+// as further UpdateSource implementations.
+func detectSources(opts options) (sourceSet, error) {
+	var sources sourceSet
+
+	if _, err := exec.LookPath("checkupdates"); err != nil {
+		return sources, errors.New("checkupdates is MIA. Install 'pacman-contrib' or rot.")
+	}
+	sources.official = pacmanSource{}
+
+	helper := opts.aurHelper
+	if helper != "" {
+		if _, err := exec.LookPath(helper); err != nil {
+			return sources, fmt.Errorf("Requested AUR helper '%s' is not in PATH.", helper)
+		}
+	} else if helper = detectAURHelper(); helper == "" {
+		return sources, errors.New("No AUR helper found. Install paru or yay.")
+	}
+	sources.aur = aurSource{helper: helper, timeout: opts.aurTimeout}
+
+	// Flatpak is optional and only checked when installed
+	if _, err := exec.LookPath("flatpak"); err == nil {
+		sources.flatpak = flatpakSource{}
+	}
+	return sources, nil
 }
 
 func detectAURHelper() string {
@@ -109,33 +444,107 @@ func detectAURHelper() string {
 }
 
 func runCommand(name string, args ...string) (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	return runCommandTimeout(context.Background(), commandTimeout, name, args...)
+}
+
+// runCommandTimeout runs a command and returns its trimmed stdout, killing it
+// after timeout or when parent is cancelled
+func runCommandTimeout(parent context.Context, timeout time.Duration, name string, args ...string) (string, error) {
+	return runCommandEnv(parent, timeout, nil, name, args...)
+}
+
+// runCommandC runs a command under the C locale, for output whose labels and
+// number formats are parsed
+func runCommandC(name string, args ...string) (string, error) {
+	return runCommandEnv(context.Background(), commandTimeout, []string{"LC_ALL=C"}, name, args...)
+}
+
+// runCommandEnv is runCommandTimeout with extra environment variables
+func runCommandEnv(parent context.Context, timeout time.Duration, env []string, name string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(parent, timeout)
 	defer cancel()
 
 	cmd := exec.CommandContext(ctx, name, args...)
+	if env != nil {
+		cmd.Env = append(os.Environ(), env...)
+	}
 	output, err := cmd.Output()
 	if err != nil {
 		if ctx.Err() == context.DeadlineExceeded {
-			return "", fmt.Errorf("command timed out")
+			return "", fmt.Errorf("command timed out after %s", timeout)
 		}
 		return "", err
 	}
 	return strings.TrimSpace(string(output)), nil
 }
 
-func fetchOfficialUpdates() (string, error) {
-	output, err := runCommand("checkupdates")
+// checkupdatesDBPath mirrors the temp database location used by checkupdates
+func checkupdatesDBPath() string {
+	if db := os.Getenv("CHECKUPDATES_DB"); db != "" {
+		return db
+	}
+	tmp := os.Getenv("TMPDIR")
+	if tmp == "" {
+		tmp = "/tmp"
+	}
+	return filepath.Join(tmp, fmt.Sprintf("checkup-db-%d", os.Getuid()))
+}
+
+// refreshSyncDB force-syncs (-Syy) checkupdates' private database so the next
+// query can't be served from stale sync files. pacman refuses to sync as a normal
+// user, so this runs under fakeroot, the same way checkupdates does; the system
+// database is never touched.
+func refreshSyncDB() error {
+	if _, err := exec.LookPath("fakeroot"); err != nil {
+		return fmt.Errorf("fakeroot is required for --refresh (install 'fakeroot' or run as root)")
+	}
+
+	dbPath := checkupdatesDBPath()
+	if err := os.MkdirAll(dbPath, 0755); err != nil {
+		return fmt.Errorf("creating %s: %w", dbPath, err)
+	}
+
+	// The temp database needs the real local database to compare against
+	systemDB, err := runCommand("pacman-conf", "DBPath")
+	if err != nil || systemDB == "" {
+		systemDB = "/var/lib/pacman/"
+	}
+	localLink := filepath.Join(dbPath, "local")
+	if _, err := os.Lstat(localLink); os.IsNotExist(err) {
+		if err := os.Symlink(filepath.Join(systemDB, "local"), localLink); err != nil {
+			return fmt.Errorf("linking local database: %w", err)
+		}
+	}
+
+	_, err = runCommand("fakeroot", "--", "pacman", "-Syy", "--dbpath", dbPath, "--logfile", "/dev/null")
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			stderr := strings.ToLower(string(exitErr.Stderr))
+			if strings.Contains(stderr, "permission denied") || strings.Contains(stderr, "you cannot perform this operation") {
+				return fmt.Errorf("permission denied syncing %s", dbPath)
+			}
+		}
+		return fmt.Errorf("database sync failed: %w", err)
+	}
+
+	// Make sure checkupdates reads the database we just synced
+	os.Setenv("CHECKUPDATES_DB", dbPath)
+	return nil
+}
+
+func fetchOfficialUpdates(ctx context.Context) (string, error) {
+	output, err := runCommandTimeout(ctx, commandTimeout, "checkupdates")
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 2 {
 			return "", nil // Exit code 2 means no updates
 		}
 		return "", err
 	}
-	return output, nil
+	return normalizeUpdates(output), nil
 }
 
-func fetchAURUpdates(aurHelper string) (string, error) {
-	output, err := runCommand(aurHelper, "-Qua")
+func fetchAURUpdates(ctx context.Context, aurHelper string, timeout time.Duration) (string, error) {
+	output, err := runCommandTimeout(ctx, timeout, aurHelper, "-Qua")
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
 			return "", nil // Exit code 1 means no updates for paru/yay
@@ -147,6 +556,98 @@ func fetchAURUpdates(aurHelper string) (string, error) {
 		return "", nil
 	}
 
+	// Drop packages the helper reports as ignored
+	var kept []string
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.HasSuffix(strings.TrimSpace(line), "[ignored]") {
+			kept = append(kept, line)
+		}
+	}
+
+	return normalizeUpdates(strings.Join(kept, "\n")), nil
+}
+
+// parseUpdateLine splits an update line into its parts. It accepts
+// "name old -> new", flatpak's "name new" and a bare "name" (after --no-ver).
+func parseUpdateLine(line string) (name, oldV, newV string) {
+	parts := strings.Fields(line)
+	switch {
+	case len(parts) == 0:
+		return "", "", ""
+	case len(parts) >= 4 && parts[2] == "->":
+		return parts[0], parts[1], parts[3]
+	case len(parts) == 2:
+		return parts[0], "", parts[1]
+	default:
+		return parts[0], "", ""
+	}
+}
+
+// formatUpdateLine is the inverse of parseUpdateLine
+func formatUpdateLine(name, oldV, newV string) string {
+	switch {
+	case oldV != "":
+		return name + " " + oldV + " -> " + newV
+	case newV != "":
+		return name + " " + newV
+	default:
+		return name
+	}
+}
+
+// normalizeUpdates rewrites every line into the canonical "name old -> new" form,
+// dropping blank lines and anything after the new version
+func normalizeUpdates(updates string) string {
+	var lines []string
+	for _, line := range strings.Split(updates, "\n") {
+		if name, oldV, newV := parseUpdateLine(line); name != "" {
+			lines = append(lines, formatUpdateLine(name, oldV, newV))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// alignUpdates pads names and old versions so the transitions line up in columns
+func alignUpdates(updates string) string {
+	type row struct{ name, oldV, newV string }
+	var rows []row
+	nameWidth, oldWidth := 0, 0
+	for _, line := range strings.Split(updates, "\n") {
+		name, oldV, newV := parseUpdateLine(line)
+		if name == "" {
+			continue
+		}
+		rows = append(rows, row{name, oldV, newV})
+		nameWidth = max(nameWidth, len(name))
+		oldWidth = max(oldWidth, len(oldV))
+	}
+
+	lines := make([]string, 0, len(rows))
+	for _, r := range rows {
+		var line string
+		switch {
+		case r.oldV != "":
+			line = fmt.Sprintf("%-*s  %-*s -> %s", nameWidth, r.name, oldWidth, r.oldV, r.newV)
+		case r.newV != "":
+			line = fmt.Sprintf("%-*s  %s", nameWidth, r.name, r.newV)
+		default:
+			line = r.name
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func fetchFlatpakUpdates(ctx context.Context) (string, error) {
+	output, err := runCommandTimeout(ctx, commandTimeout, "flatpak", "remote-ls", "--updates", "--columns=application,version")
+	if err != nil {
+		return "", err
+	}
+
+	if output == "" {
+		return "", nil
+	}
+
 	lines := strings.Split(output, "\n")
 	var builder strings.Builder
 
@@ -155,19 +656,504 @@ func fetchAURUpdates(aurHelper string) (string, error) {
 		if line == "" {
 			continue
 		}
-		// Normalize whitespace and filter ignored packages
+		// Columns are tab-separated; normalize to "app version"
 		line = strings.Join(strings.Fields(line), " ")
-		if !strings.HasSuffix(line, "[ignored]") {
-			if builder.Len() > 0 {
-				builder.WriteByte('\n')
-			}
-			builder.WriteString(line)
+		if builder.Len() > 0 {
+			builder.WriteByte('\n')
 		}
+		builder.WriteString(line)
 	}
 
 	return builder.String(), nil
 }
 
+// syncDBArgs points pacman at checkupdates' freshly synced database when it
+// exists, since the system one may be stale
+func syncDBArgs() []string {
+	if info, err := os.Stat(checkupdatesDBPath()); err == nil && info.IsDir() {
+		return []string{"--dbpath", checkupdatesDBPath()}
+	}
+	return nil
+}
+
+// fetchDownloadSize sums the sync database download sizes of the given packages.
+// Lookups run on a bounded pool; packages that can't be queried are left out
+// of the total and counted in unknown.
+func fetchDownloadSize(updates []packageUpdate) (total int64, unknown int) {
+	args := append([]string{"-Si"}, syncDBArgs()...)
+
+	jobs := make(chan string)
+	sizes := make(chan int64, len(updates))
+	var wg sync.WaitGroup
+
+	for i := 0; i < sizeLookupWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range jobs {
+				output, err := runCommandC("pacman", append(args, name)...)
+				if err != nil {
+					sizes <- -1
+					continue
+				}
+				if size, ok := parseDownloadSize(output); ok {
+					sizes <- size
+				} else {
+					sizes <- -1
+				}
+			}
+		}()
+	}
+
+	for _, update := range updates {
+		jobs <- update.Name
+	}
+	close(jobs)
+	wg.Wait()
+	close(sizes)
+
+	for size := range sizes {
+		if size < 0 {
+			unknown++
+			continue
+		}
+		total += size
+	}
+	return total, unknown
+}
+
+// parseDownloadSize extracts the "Download Size" field from pacman -Si output
+func parseDownloadSize(info string) (int64, bool) {
+	for _, line := range strings.Split(info, "\n") {
+		key, value, found := strings.Cut(line, ":")
+		if !found || strings.TrimSpace(key) != "Download Size" {
+			continue
+		}
+
+		fields := strings.Fields(value)
+		if len(fields) != 2 {
+			return 0, false
+		}
+		number, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return 0, false
+		}
+
+		multipliers := map[string]float64{
+			"B":   1,
+			"KiB": 1 << 10,
+			"MiB": 1 << 20,
+			"GiB": 1 << 30,
+		}
+		multiplier, ok := multipliers[fields[1]]
+		if !ok {
+			return 0, false
+		}
+		return int64(number * multiplier), true
+	}
+	return 0, false
+}
+
+// humanSize formats a byte count with binary units, like pacman does
+func humanSize(bytes int64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	size := float64(bytes)
+	unit := 0
+	for size >= 1024 && unit < len(units)-1 {
+		size /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%d %s", bytes, units[unit])
+	}
+	return fmt.Sprintf("%.2f %s", size, units[unit])
+}
+
+// fetchRepoMap maps every sync package to its repository, also returning the
+// repositories in pacman.conf order
+func fetchRepoMap() (map[string]string, []string, error) {
+	output, err := runCommand("pacman", append([]string{"-Sl"}, syncDBArgs()...)...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	repos := make(map[string]string)
+	var order []string
+	for _, line := range strings.Split(output, "\n") {
+		parts := strings.Fields(line)
+		if len(parts) < 2 {
+			continue
+		}
+		repo, name := parts[0], parts[1]
+		if len(order) == 0 || order[len(order)-1] != repo {
+			order = append(order, repo)
+		}
+		repos[name] = repo
+	}
+	return repos, order, nil
+}
+
+// groupByRepo buckets update lines by repository. Packages missing from the
+// sync database end up in a trailing "other" group.
+func groupByRepo(updates string, repos map[string]string, order []string) []repoGroup {
+	buckets := make(map[string][]string)
+	for _, line := range strings.Split(updates, "\n") {
+		parts := strings.Fields(line)
+		if len(parts) == 0 {
+			continue
+		}
+		repo, ok := repos[parts[0]]
+		if !ok {
+			repo = "other"
+		}
+		buckets[repo] = append(buckets[repo], line)
+	}
+
+	var groups []repoGroup
+	for _, repo := range append(order, "other") {
+		if lines, ok := buckets[repo]; ok {
+			groups = append(groups, repoGroup{repo: repo, lines: lines})
+			delete(buckets, repo)
+		}
+	}
+	return groups
+}
+
+// fetchArchNews returns the most recent items from the Arch Linux news feed
+func fetchArchNews() ([]newsItem, error) {
+	client := &http.Client{Timeout: archNewsTimeout}
+	resp, err := client.Get(archNewsURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("news feed returned %s", resp.Status)
+	}
+
+	var feed newsFeed
+	if err := xml.NewDecoder(resp.Body).Decode(&feed); err != nil {
+		return nil, fmt.Errorf("parsing news feed: %w", err)
+	}
+
+	if len(feed.Items) > archNewsItems {
+		feed.Items = feed.Items[:archNewsItems]
+	}
+	return feed.Items, nil
+}
+
+func displayNews(items []newsItem) {
+	if len(items) == 0 {
+		return
+	}
+
+	fmt.Printf("%sDispatches from Arch HQ:%s\n", term.Yellow, term.Reset)
+	for _, item := range items {
+		date := item.PubDate
+		if t, err := time.Parse(time.RFC1123Z, item.PubDate); err == nil {
+			date = t.Format("2006-01-02")
+		}
+		fmt.Printf("  %s%s%s  %s\n", term.Cyan, date, term.Reset, strings.TrimSpace(item.Title))
+	}
+	fmt.Println()
+}
+
+// fetchSnapshot queries checkupdates, the AUR helper and flatpak concurrently.
+// A flatpak failure is only a warning; the other two are fatal.
+func fetchSnapshot(ctx context.Context, opts options) (snapshot, error) {
+	sources := opts.sources
+	results := fetchAll(ctx, sources.official, sources.aur, sources.flatpak)
+	officialResult, aurResult, flatpakResult := results[0], results[1], results[2]
+
+	// Handle errors - only report actual failures, not "no updates"
+	if officialResult.err != nil {
+		return snapshot{}, fmt.Errorf("Failed to check %s updates: %v", sources.official.Name(), officialResult.err)
+	}
+	if aurResult.err != nil {
+		return snapshot{}, fmt.Errorf("Failed to check %s updates: %v", sources.aur.Name(), aurResult.err)
+	}
+
+	// Flatpak is a bonus source, so a failure there shouldn't hide pacman/AUR results
+	hasFlatpak := sources.flatpak != nil
+	if flatpakResult.err != nil {
+		fmt.Fprintf(os.Stderr, "%sFailed to check %s updates: %v%s\n", term.Yellow, sources.flatpak.Name(), flatpakResult.err, term.Reset)
+		hasFlatpak = false
+	}
+
+	return snapshot{
+		CheckedAt:  time.Now(),
+		Official:   officialResult.output,
+		AUR:        aurResult.output,
+		Flatpak:    flatpakResult.output,
+		HasFlatpak: hasFlatpak,
+	}, nil
+}
+
+// fetchAll queries the sources concurrently; nil sources are skipped and
+// leave an empty result. A panicking source is reported as an error.
+func fetchAll(ctx context.Context, sources ...UpdateSource) []updateResult {
+	results := make([]updateResult, len(sources))
+	var wg sync.WaitGroup
+	for i, source := range sources {
+		if source == nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					results[i] = updateResult{"", fmt.Errorf("panic recovered: %v", r)}
+				}
+			}()
+			output, err := source.Fetch(ctx)
+			results[i] = updateResult{output, err}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// snapshotPath is where --cache keeps the last raw query result
+func snapshotPath() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "check_updates", "result.json"), nil
+}
+
+// loadSnapshot returns the cached query result and whether it is younger than ttl.
+// A zero ttl disables the cache.
+func loadSnapshot(ttl time.Duration) (snapshot, bool) {
+	if ttl <= 0 {
+		return snapshot{}, false
+	}
+	path, err := snapshotPath()
+	if err != nil {
+		return snapshot{}, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return snapshot{}, false
+	}
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return snapshot{}, false
+	}
+	age := time.Since(snap.CheckedAt)
+	return snap, age >= 0 && age < ttl
+}
+
+func saveSnapshot(snap snapshot) error {
+	path, err := snapshotPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// lastRunPath is where the previous update set is kept, e.g. ~/.cache/check_updates/last.json
+func lastRunPath() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "check_updates", "last.json"), nil
+}
+
+// loadLastRun reads the previous update set. A missing or corrupt cache
+// yields an empty set, so everything counts as new.
+func loadLastRun() ([]packageUpdate, error) {
+	path, err := lastRunPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var last lastRun
+	if err := json.Unmarshal(data, &last); err != nil {
+		return nil, err
+	}
+	return last.Updates, nil
+}
+
+func saveLastRun(updates []packageUpdate) error {
+	path, err := lastRunPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(lastRun{CheckedAt: time.Now(), Updates: updates})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// newSince returns the names of packages whose pending version wasn't
+// pending in the previous run
+func newSince(previous, current []packageUpdate) map[string]bool {
+	seen := make(map[string]bool, len(previous))
+	for _, update := range previous {
+		seen[update.Name+" "+update.NewVersion] = true
+	}
+
+	fresh := make(map[string]bool)
+	for _, update := range current {
+		if !seen[update.Name+" "+update.NewVersion] {
+			fresh[update.Name] = true
+		}
+	}
+	return fresh
+}
+
+// filterUpdates keeps only lines whose package name matches one of the glob patterns
+func filterUpdates(updates string, patterns []string) string {
+	if updates == "" {
+		return ""
+	}
+
+	var kept []string
+	for _, line := range strings.Split(updates, "\n") {
+		parts := strings.Fields(line)
+		if len(parts) == 0 {
+			continue
+		}
+		for _, pattern := range patterns {
+			if matched, _ := path.Match(pattern, parts[0]); matched {
+				kept = append(kept, line)
+				break
+			}
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// ignoreListPath is the user-local list of packages that are never reported
+func ignoreListPath() (string, error) {
+	return configFilePath("ignore")
+}
+
+// configFilePath returns a file in the check_updates user config directory
+func configFilePath(name string) (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "check_updates", name), nil
+}
+
+// readListFile reads one entry per line; blank lines and # comments are skipped.
+// A missing file is reported as os.ErrNotExist.
+func readListFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		if entry := strings.TrimSpace(line); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// loadIgnoreList reads the ignore list. A missing file is an empty list.
+func loadIgnoreList() (map[string]bool, error) {
+	path, err := ignoreListPath()
+	if err != nil {
+		return nil, err
+	}
+	names, err := readListFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ignored := make(map[string]bool)
+	for _, name := range names {
+		ignored[name] = true
+	}
+	return ignored, nil
+}
+
+// defaultRebootPackages are globs for packages whose update usually calls for
+// a reboot or a DKMS rebuild. ~/.config/check_updates/reboot replaces them.
+var defaultRebootPackages = []string{
+	"linux", "linux-lts", "linux-zen", "linux-hardened",
+	"nvidia*", "*-ucode", "systemd", "glibc",
+}
+
+// loadRebootPackages returns the user's reboot-sensitive globs, or the defaults
+// when no list file exists.
+func loadRebootPackages() ([]string, error) {
+	path, err := configFilePath("reboot")
+	if err != nil {
+		return defaultRebootPackages, err
+	}
+	patterns, err := readListFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return defaultRebootPackages, nil
+	}
+	if err != nil {
+		return defaultRebootPackages, err
+	}
+	return patterns, nil
+}
+
+// rebootSensitive lists the pending packages that match one of the patterns
+func rebootSensitive(patterns []string, updateLists ...string) []string {
+	var names []string
+	for _, updates := range updateLists {
+		for _, line := range strings.Split(updates, "\n") {
+			name, _, _ := parseUpdateLine(line)
+			if name == "" {
+				continue
+			}
+			for _, pattern := range patterns {
+				if matched, _ := path.Match(pattern, name); matched {
+					names = append(names, name)
+					break
+				}
+			}
+		}
+	}
+	return names
+}
+
+// dropIgnored removes lines for packages in the ignore list
+func dropIgnored(updates string, ignored map[string]bool) string {
+	if updates == "" || len(ignored) == 0 {
+		return updates
+	}
+
+	var kept []string
+	for _, line := range strings.Split(updates, "\n") {
+		if name, _, _ := parseUpdateLine(line); name != "" && !ignored[name] {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
 func stripVersions(updates string) string {
 	if updates == "" {
 		return ""
@@ -177,22 +1163,95 @@ func stripVersions(updates string) string {
 	var builder strings.Builder
 
 	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-		parts := strings.Fields(line)
-		if len(parts) > 0 {
+		if name, _, _ := parseUpdateLine(line); name != "" {
 			if builder.Len() > 0 {
 				builder.WriteByte('\n')
 			}
-			builder.WriteString(parts[0])
+			builder.WriteString(name)
 		}
 	}
 
 	return builder.String()
 }
 
+// parseUpdates turns "name old -> new" (or flatpak's "name new") lines into structured updates
+func parseUpdates(updates string) []packageUpdate {
+	parsed := []packageUpdate{}
+	if updates == "" {
+		return parsed
+	}
+
+	for _, line := range strings.Split(updates, "\n") {
+		name, oldV, newV := parseUpdateLine(line)
+		if name == "" {
+			continue
+		}
+		parsed = append(parsed, packageUpdate{Name: name, OldVersion: oldV, NewVersion: newV})
+	}
+
+	return parsed
+}
+
+func printJSON(official, aur, flatpak string) error {
+	report := jsonReport{
+		Official: parseUpdates(official),
+		AUR:      parseUpdates(aur),
+		Flatpak:  parseUpdates(flatpak),
+	}
+	report.OfficialCount = len(report.Official)
+	report.AURCount = len(report.AUR)
+	report.FlatpakCount = len(report.Flatpak)
+	report.Total = report.OfficialCount + report.AURCount + report.FlatpakCount
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// sendNotification pops a desktop notification summarizing pending updates.
+// Nothing is sent when the system is up to date.
+func sendNotification(officialCount, aurCount, flatpakCount int) error {
+	if officialCount+aurCount+flatpakCount == 0 {
+		return nil
+	}
+	if _, err := exec.LookPath("notify-send"); err != nil {
+		return fmt.Errorf("notify-send not found in PATH")
+	}
+
+	summary := fmt.Sprintf("%d official, %d AUR", officialCount, aurCount)
+	if flatpakCount > 0 {
+		summary += fmt.Sprintf(", %d flatpak", flatpakCount)
+	}
+	summary += " updates available"
+
+	_, err := runCommand("notify-send", "--app-name=check_updates", "--icon=system-software-update", "Updates available", summary)
+	return err
+}
+
+// formatCounts fills the --format template with the pending update counts
+func formatCounts(template string, officialCount, aurCount, flatpakCount int) string {
+	return strings.NewReplacer(
+		"{official}", strconv.Itoa(officialCount),
+		"{aur}", strconv.Itoa(aurCount),
+		"{flatpak}", strconv.Itoa(flatpakCount),
+		"{total}", strconv.Itoa(officialCount+aurCount+flatpakCount),
+	).Replace(template)
+}
+
+// pendingExitCode maps pending update counts to the --quiet-exit status
+func pendingExitCode(officialCount, aurCount int) int {
+	switch {
+	case officialCount > 0 && aurCount > 0:
+		return exitBothPending
+	case officialCount > 0:
+		return exitOfficialPending
+	case aurCount > 0:
+		return exitAURPending
+	default:
+		return 0
+	}
+}
+
 func countUpdates(updates string) int {
 	if updates == "" {
 		return 0
@@ -210,26 +1269,78 @@ func countUpdates(updates string) int {
 	return count
 }
 
-func displayResults(official, aur string) {
+func displayResults(official, aur, flatpak string, opts displayOptions) {
 	officialCount := countUpdates(official)
 	aurCount := countUpdates(aur)
+	flatpakCount := countUpdates(flatpak)
 
-	if officialCount == 0 && aurCount == 0 {
-		fmt.Printf("%sAll patched. The universe is in balance.%s\n", colorGreen, colorReset)
+	if officialCount == 0 && aurCount == 0 && flatpakCount == 0 {
+		fmt.Printf("%sAll patched. The universe is in balance.%s\n", term.Green, term.Reset)
 		return
 	}
 
+	if len(opts.rebootNeeded) > 0 {
+		fmt.Printf("%s%s⚠ Reboot incoming: %s. Plan a restart (and DKMS rebuilds) after upgrading.%s\n\n", term.Bold, term.Red, strings.Join(opts.rebootNeeded, ", "), term.Reset)
+	}
+
 	if officialCount > 0 {
-		fmt.Printf("%sThe mothership is hailing: %s%d%s new directives.%s\n", colorGreen, colorCyan, officialCount, colorGreen, colorReset)
-		fmt.Println(official)
+		fmt.Printf("%sThe mothership is hailing: %s%d%s new directives.%s\n", term.Green, term.Cyan, officialCount, term.Green, term.Reset)
+		if opts.repoGroups != nil {
+			for _, group := range opts.repoGroups {
+				fmt.Printf("%s[%s]%s\n", term.Cyan, group.repo, term.Reset)
+				printUpdates(strings.Join(group.lines, "\n"), opts)
+			}
+		} else {
+			printUpdates(official, opts)
+		}
+		switch {
+		case opts.downloadSize < 0:
+		case opts.sizeUnknown > 0 && opts.downloadSize == 0:
+			fmt.Printf("%sTotal download: %sunknown%s\n", term.Green, term.Yellow, term.Reset)
+		case opts.sizeUnknown > 0:
+			fmt.Printf("%sTotal download: %sat least %s%s (size unknown for %d packages)\n", term.Green, term.Cyan, humanSize(opts.downloadSize), term.Reset, opts.sizeUnknown)
+		default:
+			fmt.Printf("%sTotal download: %s%s%s\n", term.Green, term.Cyan, humanSize(opts.downloadSize), term.Reset)
+		}
 	} else {
-		fmt.Printf("%sMainline is stable. As it should be.%s\n", colorGreen, colorReset)
+		fmt.Printf("%sMainline is stable. As it should be.%s\n", term.Green, term.Reset)
 	}
 
 	if aurCount > 0 {
-		fmt.Printf("%s%s%d%s new AUR bounties.%s\n", colorYellow, colorCyan, aurCount, colorYellow, colorReset)
-		fmt.Println(aur)
+		fmt.Printf("%s%s%d%s new AUR bounties.%s\n", term.Yellow, term.Cyan, aurCount, term.Yellow, term.Reset)
+		printUpdates(aur, opts)
+	} else {
+		fmt.Printf("%sAUR sleeps. Silence is deadly.%s\n", term.Green, term.Reset)
+	}
+
+	if !opts.showFlatpak {
+		return
+	}
+
+	if flatpakCount > 0 {
+		fmt.Printf("%s%s%d%s flatpaks want a refresh.%s\n", term.Yellow, term.Cyan, flatpakCount, term.Yellow, term.Reset)
+		printUpdates(flatpak, opts)
 	} else {
-		fmt.Printf("%sAUR sleeps. Silence is deadly.%s\n", colorGreen, colorReset)
+		fmt.Printf("%sFlatpaks are frozen in time. Good.%s\n", term.Green, term.Reset)
+	}
+}
+
+// printUpdates prints update lines, marking packages that are new since the
+// previous run when --diff is active
+func printUpdates(updates string, opts displayOptions) {
+	if opts.columns {
+		updates = alignUpdates(updates)
+	}
+	if opts.newPackages == nil {
+		fmt.Println(updates)
+		return
+	}
+
+	for _, line := range strings.Split(updates, "\n") {
+		if name, _, _ := parseUpdateLine(line); opts.newPackages[name] {
+			fmt.Printf("%s%s+ %s%s\n", term.Bold, term.Yellow, line, term.Reset)
+		} else {
+			fmt.Printf("  %s\n", line)
+		}
 	}
 }
@@ -1,30 +1,39 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"hash/fnv"
+	"io"
+	"mime"
+	"net"
 	"net/http"
+	"net/http/cookiejar"
 	"net/url"
 	"os"
 	"os/exec"
 	"os/signal"
+	"path"
 	"path/filepath"
 	"regexp"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
-)
 
-const (
-	colorRed    = "\033[31m"
-	colorGreen  = "\033[32m"
-	colorYellow = "\033[33m"
-	colorCyan   = "\033[36m"
-	colorReset  = "\033[0m"
+	"github.com/Evren-os/GoferShell/internal/term"
+	"github.com/Evren-os/GoferShell/internal/version"
 )
 
 // Pre-compiled regex patterns for better performance
@@ -32,6 +41,16 @@ var (
 	contentDispositionFilenameStarRe = regexp.MustCompile(`filename\*\s*=\s*([^;]+)`)
 	contentDispositionFilenameRe     = regexp.MustCompile(`filename\s*=\s*([^;]+)`)
 	dangerousCharsRe                 = regexp.MustCompile(`[<>:"/\\|?*]`)
+	speedRe                          = regexp.MustCompile(`^(\d+)([KkMm]?)$`)
+)
+
+// errSkipped marks a download the user chose not to perform
+var errSkipped = errors.New("skipped: file already exists")
+
+// Serializes overwrite prompts so parallel downloads don't interleave questions
+var (
+	promptMu    sync.Mutex
+	stdinReader = bufio.NewReader(os.Stdin)
 )
 
 const (
@@ -41,63 +60,319 @@ const (
 	defaultConnectTimeout    = 30
 	defaultMaxTries          = 5
 	defaultRetryWait         = 10
+	filenameLookupWorkers    = 8
+	rpcStartupTimeout        = 5 * time.Second
+	speedtestDuration        = 5 * time.Second
+	defaultMaxRedirects      = 10
+	autoParallelMax          = 8               // --auto-parallel never runs more downloads than this
+	autoPerHostDownloads     = 2               // --auto-parallel downloads per host unless --per-host says otherwise
+	killGracePeriod          = 5 * time.Second // time aria2c gets to save its .aria2 file after SIGTERM
 )
 
+// browserUserAgent is the --browser-ua preset, a current desktop Firefox
+const browserUserAgent = "Mozilla/5.0 (X11; Linux x86_64; rv:131.0) Gecko/20100101 Firefox/131.0"
+
 type Config struct {
 	Destination       string
 	MaxSpeed          string
+	MaxTotalSpeed     string
 	Timeout           int
 	ConnectTimeout    int
 	MaxTries          int
 	RetryWait         int
 	UserAgent         string
+	Referer           string
 	ParallelDownloads int
 	Quiet             bool
+	SaveMetadata      bool
+	AllowFallback     bool
+	UseHTTP           bool // set at startup when aria2c is missing and fallback is allowed
+	CookieFile        string
+	CookieJar         http.CookieJar
+	NoClobber         bool
+	Interactive       bool
+	UseRPC            bool
+	GuessExtension    bool
+	Stats             bool
+	FailFast          bool
+	TempDir           string
+	NoContinue        bool
+	NoIntegrityCheck  bool
+	IPVersion         int // 4 or 6 to prefer that address family, 0 for system default
+	HTTPUser          string
+	HTTPPasswd        string
+	BearerToken       string
+	ResumeBatch       bool
+	OutputFile        string // "-" streams the single download to stdout
+	MaxRedirects      int
+	MirrorPath        bool
+	CutDirs           int
+	SkipInvalid       bool
+	ProgressJSON      bool // emit progressEvent lines on stdout instead of human output
+	SummaryFile       string
+	PerHostLimit      int // parallel downloads allowed per host, 0 for no limit
+}
+
+// downloadJob is one requested URL, with the directory an -i line asked for
+type downloadJob struct {
+	URL string
+	Dir string // empty means the -d destination
 }
 
 type DownloadItem struct {
-	URL      string
-	Filename string
-	FilePath string
-	Error    error
+	URL       string
+	Host      string
+	TargetDir string
+	WorkDir   string // where the transfer happens; under --temp-dir when set
+	Filename  string
+	FilePath  string
+	Remote    *RemoteInfo
+	Error     error
+	Size      int64         // bytes on disk after a successful download
+	Duration  time.Duration // time spent transferring
 }
 
-// detectFilename makes an HTTP HEAD request to determine the actual filename
-func detectFilename(ctx context.Context, rawURL, userAgent string, timeout int) (string, error) {
-	client := &http.Client{
-		Timeout: time.Duration(timeout) * time.Second,
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			if len(via) >= 10 {
-				return errors.New("too many redirects")
+// batchStateFile records finished batch downloads in the target directory
+const batchStateFile = ".dlfast-state.json"
+
+// batchState tracks which URLs of a batch have finished so an interrupted run
+// can be picked up again with --resume-batch. Each completion is saved right
+// away; the file is removed once the whole batch succeeds.
+type batchState struct {
+	path      string
+	mu        sync.Mutex
+	Completed map[string]string `json:"completed"` // URL -> final file path
+}
+
+func newBatchState(targetDir string) *batchState {
+	return &batchState{
+		path:      filepath.Join(targetDir, batchStateFile),
+		Completed: make(map[string]string),
+	}
+}
+
+// load reads a previous run's state; a missing file just means nothing is done yet.
+func (s *batchState) load() error {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return fmt.Errorf("parsing %s: %w", s.path, err)
+	}
+	if s.Completed == nil {
+		s.Completed = make(map[string]string)
+	}
+	return nil
+}
+
+// pending drops jobs finished in an earlier run whose file is still on disk.
+// Completed files that have since been deleted are downloaded again.
+func (s *batchState) pending(jobs []downloadJob, config *Config) []downloadJob {
+	var remaining []downloadJob
+	for _, job := range jobs {
+		path, done := s.Completed[job.URL]
+		if done {
+			if _, err := os.Stat(path); err == nil {
+				if !config.Quiet {
+					fmt.Printf("%s⏭️  Already completed in an earlier run: %s%s\n", term.Yellow, path, term.Reset)
+				}
+				continue
 			}
-			return nil
-		},
+			delete(s.Completed, job.URL)
+		}
+		remaining = append(remaining, job)
 	}
+	return remaining
+}
 
-	req, err := http.NewRequestWithContext(ctx, "HEAD", rawURL, nil)
+// markDone records a finished download and rewrites the state file.
+func (s *batchState) markDone(item *DownloadItem) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Completed[item.URL] = item.FilePath
+	data, err := json.MarshalIndent(s, "", "  ")
 	if err != nil {
-		return "", fmt.Errorf("creating request: %w", err)
+		return err
+	}
+	// Write-then-rename so an interruption never leaves a truncated file.
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// hostStats aggregates completed downloads per host for --stats
+type hostStats struct {
+	Files    int
+	Bytes    int64
+	Duration time.Duration
+}
+
+// RemoteInfo holds what the server told us about a file in its HEAD response
+type RemoteInfo struct {
+	Filename      string
+	FinalURL      string
+	ContentType   string
+	ETag          string
+	LastModified  string
+	ContentLength int64
+}
+
+// DownloadMetadata is the content of the <filename>.meta.json sidecar file
+type DownloadMetadata struct {
+	SourceURL    string    `json:"source_url"`
+	FinalURL     string    `json:"final_url"`
+	ContentType  string    `json:"content_type,omitempty"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	DownloadedAt time.Time `json:"downloaded_at"`
+	Size         int64     `json:"size"`
+}
+
+// dialPreferring returns a DialContext that tries the --ip-version address family
+// first and falls back to the other one if that fails.
+func dialPreferring(ipVersion int) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if ipVersion == 0 {
+		return dialer.DialContext
+	}
+	first, second := "tcp4", "tcp6"
+	if ipVersion == 6 {
+		first, second = "tcp6", "tcp4"
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if network != "tcp" {
+			return dialer.DialContext(ctx, network, addr)
+		}
+		conn, err := dialer.DialContext(ctx, first, addr)
+		if err == nil || ctx.Err() != nil {
+			return conn, err
+		}
+		if conn, fallbackErr := dialer.DialContext(ctx, second, addr); fallbackErr == nil {
+			return conn, nil
+		}
+		return nil, err
 	}
+}
 
-	if userAgent != "" {
-		req.Header.Set("User-Agent", userAgent)
+// setRequestHeaders adds the headers every request dlfast makes itself should carry
+func setRequestHeaders(req *http.Request, config *Config) {
+	if config.UserAgent != "" {
+		req.Header.Set("User-Agent", config.UserAgent)
 	} else {
 		req.Header.Set("User-Agent", "dlfast/1.0")
 	}
+	if config.Referer != "" {
+		req.Header.Set("Referer", config.Referer)
+	}
+	if config.HTTPUser != "" {
+		req.SetBasicAuth(config.HTTPUser, config.HTTPPasswd)
+	}
+	if config.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+config.BearerToken)
+	}
+}
+
+// redirectLimit is a CheckRedirect that follows at most limit redirects
+func redirectLimit(limit int) func(req *http.Request, via []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) > limit {
+			return fmt.Errorf("stopped after %d redirects (--max-redirects)", limit)
+		}
+		return nil
+	}
+}
+
+// detectFilename makes an HTTP HEAD request to determine the actual filename
+// and captures the response headers worth keeping
+func detectFilename(ctx context.Context, rawURL string, config *Config) (*RemoteInfo, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialPreferring(config.IPVersion)
+	client := &http.Client{
+		Timeout:       time.Duration(config.ConnectTimeout) * time.Second,
+		Jar:           config.CookieJar,
+		Transport:     transport,
+		CheckRedirect: redirectLimit(config.MaxRedirects),
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "HEAD", rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	setRequestHeaders(req, config)
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("HTTP HEAD request: %w", err)
+		return nil, fmt.Errorf("HTTP HEAD request: %w", err)
 	}
 	defer resp.Body.Close()
 
-	// Try Content-Disposition header first
+	info := &RemoteInfo{
+		FinalURL:      resp.Request.URL.String(),
+		ContentType:   resp.Header.Get("Content-Type"),
+		ETag:          resp.Header.Get("ETag"),
+		LastModified:  resp.Header.Get("Last-Modified"),
+		ContentLength: resp.ContentLength,
+	}
+
+	// Try Content-Disposition header first, then fall back to the URL
 	if filename := parseContentDisposition(resp.Header.Get("Content-Disposition")); filename != "" {
-		return sanitizeFilename(filename), nil
+		info.Filename = sanitizeFilename(filename)
+	} else {
+		info.Filename = inferFilenameFromURL(rawURL)
 	}
 
-	// Fallback to URL-based filename
-	return inferFilenameFromURL(rawURL), nil
+	if config.GuessExtension && filepath.Ext(info.Filename) == "" {
+		info.Filename += extensionForType(info.ContentType)
+	}
+	return info, nil
+}
+
+// preferredExtensions picks the usual extension for types where mime.ExtensionsByType
+// would return several candidates in alphabetical order (e.g. .jfif before .jpg)
+var preferredExtensions = map[string]string{
+	"application/gzip":                      ".gz",
+	"application/json":                      ".json",
+	"application/pdf":                       ".pdf",
+	"application/vnd.debian.binary-package": ".deb",
+	"application/x-7z-compressed":           ".7z",
+	"application/x-bzip2":                   ".bz2",
+	"application/x-iso9660-image":           ".iso",
+	"application/x-tar":                     ".tar",
+	"application/x-xz":                      ".xz",
+	"application/zip":                       ".zip",
+	"audio/mpeg":                            ".mp3",
+	"image/jpeg":                            ".jpg",
+	"image/png":                             ".png",
+	"text/html":                             ".html",
+	"text/plain":                            ".txt",
+	"video/mp4":                             ".mp4",
+}
+
+// extensionForType maps a Content-Type header to a file extension, or "" if unknown.
+// Generic binary types are ignored since they say nothing about the content.
+func extensionForType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType == "application/octet-stream" {
+		return ""
+	}
+	if ext, ok := preferredExtensions[mediaType]; ok {
+		return ext
+	}
+	if exts, err := mime.ExtensionsByType(mediaType); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
 }
 
 // parseContentDisposition parses RFC 6266 Content-Disposition header
@@ -155,6 +430,27 @@ func sanitizeFilename(filename string) string {
 	return filename
 }
 
+// mirrorSubdir returns the directories of the URL path, minus the first
+// cutDirs of them, as a relative path for --mirror-path. Components are
+// sanitized and the path is cleaned first, so ".." can't climb out of -d.
+func mirrorSubdir(rawURL string, cutDirs int) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	var parts []string
+	for _, part := range strings.Split(path.Dir(path.Clean("/"+u.Path)), "/") {
+		part = strings.Trim(dangerousCharsRe.ReplaceAllString(part, "_"), " .")
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if cutDirs >= len(parts) {
+		return ""
+	}
+	return filepath.Join(parts[cutDirs:]...)
+}
+
 // isReservedName checks for Windows reserved filenames
 func isReservedName(name string) bool {
 	reserved := []string{"CON", "PRN", "AUX", "NUL", "COM1", "COM2", "COM3", "COM4",
@@ -177,11 +473,14 @@ func inferFilenameFromURL(rawURL string) string {
 		return fmt.Sprintf("download_error_%s", time.Now().Format("20060102150405"))
 	}
 
-	path := u.Path
+	// Take the last segment of the still-escaped path and unescape only that,
+	// so "My%20File.zip" gives "My File.zip" and an encoded "%2F" stays part of
+	// the name instead of being treated as a directory separator.
+	path := u.EscapedPath()
 	if strings.HasSuffix(path, "/") && len(path) > 1 {
 		path = path[:len(path)-1]
 	}
-	filename := filepath.Base(path)
+	filename := unescapeSegment(filepath.Base(path))
 
 	if filename == "" || filename == "." || filename == "/" {
 		if u.Host != "" {
@@ -194,12 +493,36 @@ func inferFilenameFromURL(rawURL string) string {
 	return sanitizeFilename(filename)
 }
 
+// unescapeSegment decodes one escaped URL path segment. A segment with a
+// malformed escape such as "100%.zip" is returned as is.
+func unescapeSegment(segment string) string {
+	if unescaped, err := url.PathUnescape(segment); err == nil {
+		return unescaped
+	}
+	return segment
+}
+
 // buildAria2cArgs constructs optimized aria2c arguments
 func buildAria2cArgs(targetDir, filename, url string, config *Config) []string {
 	args := []string{
 		"--dir=" + targetDir,
 		"--out=" + filename,
-		"--continue=true",
+	}
+	args = append(args, aria2cTransferArgs(config)...)
+
+	if limit := perFileSpeedLimit(config); limit != "" {
+		args = append(args, "--max-download-limit="+limit)
+	}
+
+	args = append(args, url)
+	return args
+}
+
+// aria2cTransferArgs returns the connection, retry and file options shared by
+// per-file aria2c runs and the --rpc daemon
+func aria2cTransferArgs(config *Config) []string {
+	args := []string{
+		"--continue=" + strconv.FormatBool(!config.NoContinue),
 		"--max-connection-per-server=" + strconv.Itoa(maxConnectionsPerServer),
 		"--split=32",
 		"--min-split-size=1M",
@@ -214,25 +537,211 @@ func buildAria2cArgs(targetDir, filename, url string, config *Config) []string {
 		"--auto-file-renaming=false",
 		"--allow-overwrite=true",
 		"--conditional-get=true",
-		"--check-integrity=true",
+		"--check-integrity=" + strconv.FormatBool(!config.NoIntegrityCheck),
 		"--disk-cache=128M",
 		"--async-dns=true",
 		"--http-accept-gzip=true",
 		"--remote-time=true",
 	}
 
-	if config.MaxSpeed != "" {
-		args = append(args, "--max-download-limit="+config.MaxSpeed)
-	}
-
 	if config.UserAgent != "" {
 		args = append(args, "--user-agent="+config.UserAgent)
 	}
 
-	args = append(args, url)
+	if config.Referer != "" {
+		args = append(args, "--referer="+config.Referer)
+	}
+
+	if config.CookieFile != "" {
+		args = append(args, "--load-cookies="+config.CookieFile)
+	}
+
+	if config.HTTPUser != "" {
+		args = append(args, "--http-user="+config.HTTPUser, "--http-passwd="+config.HTTPPasswd)
+	}
+	if config.BearerToken != "" {
+		args = append(args, "--header=Authorization: Bearer "+config.BearerToken)
+	}
+
+	// aria2c has no way to prefer IPv6, only to turn it off.
+	if config.IPVersion == 4 {
+		args = append(args, "--disable-ipv6=true")
+	}
+
 	return args
 }
 
+// redactedValue replaces secrets in command lines shown to the user
+const redactedValue = "***"
+
+// redactArgs returns a copy of an aria2c argument list with credentials masked,
+// so it can be printed without leaking them. Header values, passwords, bearer
+// tokens and the password part of URLs (including proxies) are replaced.
+func redactArgs(args []string) []string {
+	redacted := make([]string, len(args))
+	maskNext := false
+	for i, arg := range args {
+		if maskNext {
+			redacted[i] = redactOptionValue(args[i-1], arg)
+			maskNext = false
+			continue
+		}
+		name, value, hasValue := strings.Cut(arg, "=")
+		switch {
+		case !strings.HasPrefix(arg, "--"):
+			redacted[i] = redactURL(arg)
+		case !isSensitiveOption(name):
+			redacted[i] = arg
+		case hasValue:
+			redacted[i] = name + "=" + redactOptionValue(name, value)
+		default:
+			// The value is in the next argument: --header 'Authorization: ...'
+			redacted[i] = arg
+			maskNext = true
+		}
+	}
+	return redacted
+}
+
+// isSensitiveOption reports whether an aria2c option's value may carry credentials.
+func isSensitiveOption(name string) bool {
+	switch name {
+	case "--header", "--bearer":
+		return true
+	}
+	return strings.HasSuffix(name, "-passwd") || strings.HasSuffix(name, "-proxy")
+}
+
+// redactOptionValue masks the value of a sensitive option. Headers keep their
+// name so the output still shows which header was sent, and proxy URLs keep
+// everything but the password.
+func redactOptionValue(name, value string) string {
+	switch {
+	case name == "--header":
+		if field, _, ok := strings.Cut(value, ":"); ok {
+			return field + ": " + redactedValue
+		}
+		return redactedValue
+	case strings.HasSuffix(name, "-proxy"):
+		return redactURL(value)
+	}
+	return redactedValue
+}
+
+// redactURL hides the password in a URL with user info (as "xxxxx", like
+// url.URL.Redacted), leaving anything else as is.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw
+	}
+	return u.Redacted()
+}
+
+// parseSpeed converts an aria2c-style speed such as 500K or 2M to bytes per second.
+func parseSpeed(speed string) (int64, error) {
+	m := speedRe.FindStringSubmatch(speed)
+	if m == nil {
+		return 0, fmt.Errorf("invalid speed %q (e.g., 1M, 500K)", speed)
+	}
+	n, err := strconv.ParseInt(m[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid speed %q: %w", speed, err)
+	}
+	switch strings.ToUpper(m[2]) {
+	case "K":
+		n *= 1024
+	case "M":
+		n *= 1024 * 1024
+	}
+	return n, nil
+}
+
+// perFileSpeedLimit returns the --max-download-limit value for one aria2c process.
+// --max-total-speed is approximated by splitting it evenly across the parallel
+// slots; a slot that finishes early does not hand its share to the others.
+func perFileSpeedLimit(config *Config) string {
+	if config.MaxTotalSpeed == "" {
+		return config.MaxSpeed
+	}
+	total, err := parseSpeed(config.MaxTotalSpeed)
+	if err != nil {
+		return config.MaxSpeed
+	}
+	share := max(total/int64(max(config.ParallelDownloads, 1)), 1)
+	if config.MaxSpeed != "" {
+		if perFile, err := parseSpeed(config.MaxSpeed); err == nil && perFile < share {
+			return config.MaxSpeed
+		}
+	}
+	return strconv.FormatInt(share, 10)
+}
+
+// loadCookieJar reads a Netscape-format cookie file into a cookie jar
+func loadCookieJar(path string) (http.CookieJar, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening cookie file: %w", err)
+	}
+	defer f.Close()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating cookie jar: %w", err)
+	}
+
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+
+		// curl and browsers export HttpOnly cookies with this prefix
+		httpOnly := false
+		if strings.HasPrefix(line, "#HttpOnly_") {
+			line = strings.TrimPrefix(line, "#HttpOnly_")
+			httpOnly = true
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// domain, include-subdomains, path, secure, expiry, name, value
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("cookie file line %d: expected 7 tab-separated fields, got %d", lineNum, len(fields))
+		}
+
+		host := strings.TrimPrefix(fields[0], ".")
+		secure := strings.EqualFold(fields[3], "TRUE")
+		cookie := &http.Cookie{
+			Name:     fields[5],
+			Value:    fields[6],
+			Path:     fields[2],
+			Secure:   secure,
+			HttpOnly: httpOnly,
+		}
+		if strings.EqualFold(fields[1], "TRUE") {
+			cookie.Domain = host
+		}
+		if expiry, err := strconv.ParseInt(fields[4], 10, 64); err == nil && expiry > 0 {
+			cookie.Expires = time.Unix(expiry, 0)
+		}
+
+		scheme := "http"
+		if secure {
+			scheme = "https"
+		}
+		jar.SetCookies(&url.URL{Scheme: scheme, Host: host, Path: cookie.Path}, []*http.Cookie{cookie})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading cookie file: %w", err)
+	}
+
+	return jar, nil
+}
+
 // validateURL performs comprehensive URL validation
 func validateURL(rawURL string) error {
 	if rawURL == "" {
@@ -255,6 +764,45 @@ func validateURL(rawURL string) error {
 	return nil
 }
 
+// readJobFile reads URLs from a file, or stdin when path is "-", one per line.
+// A URL may be followed by a tab or | and a directory to save that file in,
+// overriding -d; a leading ~/ is expanded. Blank lines and # comments are ignored.
+func readJobFile(path string) ([]downloadJob, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []downloadJob
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rawURL, dir, found := strings.Cut(line, "\t")
+		if !found {
+			rawURL, dir, _ = strings.Cut(line, "|")
+		}
+		dir = strings.TrimSpace(dir)
+		if rest, ok := strings.CutPrefix(dir, "~/"); ok {
+			// No shell expands ~ inside the file.
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil, err
+			}
+			dir = filepath.Join(home, rest)
+		}
+		jobs = append(jobs, downloadJob{URL: strings.TrimSpace(rawURL), Dir: dir})
+	}
+	return jobs, nil
+}
+
 // setupDestination determines target directory and creates it if necessary
 func setupDestination(destination string) (string, error) {
 	var targetDir string
@@ -296,140 +844,901 @@ func setupDestination(destination string) (string, error) {
 	return targetDir, nil
 }
 
-// downloadFile performs a single download with aria2c
-func downloadFile(ctx context.Context, item *DownloadItem, targetDir string, config *Config) error {
-	if !config.Quiet {
-		fmt.Printf("🔍 Detecting filename for: %s%s%s\n", colorCyan, item.URL, colorReset)
-	}
+// resolveAllFilenames detects the remote filename of every download up front,
+// issuing the HEAD requests concurrently, and fills in Remote, Filename and FilePath.
+func resolveAllFilenames(ctx context.Context, downloads []DownloadItem, config *Config) {
+	sem := make(chan struct{}, filenameLookupWorkers)
+	var wg sync.WaitGroup
 
-	// Detect actual filename
-	filename, err := detectFilename(ctx, item.URL, config.UserAgent, config.ConnectTimeout)
-	if err != nil {
-		if !config.Quiet {
-			fmt.Printf("%s⚠️  Could not detect filename, using URL fallback: %v%s\n", colorYellow, err, colorReset)
-		}
-		// Fallback to URL-based inference on error
-		filename = inferFilenameFromURL(item.URL)
-	}
+	for i := range downloads {
+		wg.Add(1)
+		go func(item *DownloadItem) {
+			defer wg.Done()
 
-	item.Filename = filename
-	item.FilePath = filepath.Join(targetDir, filename)
+			sem <- struct{}{}
+			defer func() { <-sem }()
 
-	if !config.Quiet {
-		fmt.Printf("📥 Downloading: %s%s%s → %s%s%s\n", colorCyan, item.URL, colorReset, colorCyan, item.FilePath, colorReset)
-	}
+			if !config.Quiet {
+				fmt.Printf("🔍 Detecting filename for: %s%s%s\n", term.Cyan, item.URL, term.Reset)
+			}
 
-	args := buildAria2cArgs(targetDir, filename, item.URL, config)
+			remote, err := detectFilename(ctx, item.URL, config)
+			if err != nil {
+				if !config.Quiet {
+					fmt.Printf("%s⚠️  Could not detect filename for %s, using URL fallback: %v%s\n", term.Yellow, item.URL, err, term.Reset)
+				}
+				// Fallback to URL-based inference on error
+				remote = &RemoteInfo{
+					Filename:      inferFilenameFromURL(item.URL),
+					FinalURL:      item.URL,
+					ContentLength: -1,
+				}
+			}
 
-	cmd := exec.CommandContext(ctx, "aria2c", args...)
+			item.Remote = remote
+			item.Filename = remote.Filename
+			item.FilePath = filepath.Join(item.TargetDir, remote.Filename)
+		}(&downloads[i])
+	}
 
-	// Create new process group for proper signal handling
-	cmd.SysProcAttr = &syscall.SysProcAttr{
-		Setpgid: true,
+	wg.Wait()
+}
+
+// downloadFile performs a single download of an item whose filename is already resolved.
+func downloadFile(ctx context.Context, item *DownloadItem, config *Config) error {
+	if err := resolveExistingFile(item, config); err != nil {
+		return err
 	}
 
-	// Let aria2c output directly to terminal (unless quiet mode)
 	if !config.Quiet {
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-	} else {
-		// In quiet mode, capture stderr for error reporting
-		cmd.Stderr = os.Stderr
+		fmt.Printf("📥 Downloading: %s%s%s → %s%s%s\n", term.Cyan, item.URL, term.Reset, term.Cyan, item.FilePath, term.Reset)
 	}
 
-	err = cmd.Run()
+	// With --temp-dir the transfer happens there and the result is moved into place.
+	workDir, finalPath := item.WorkDir, item.FilePath
+	if workDir != item.TargetDir {
+		item.FilePath = filepath.Join(workDir, item.Filename)
+	}
 
+	var err error
+	start := time.Now()
+	if config.UseHTTP {
+		err = downloadWithHTTP(ctx, item, workDir, config)
+	} else {
+		err = downloadWithAria2c(ctx, item, workDir, config)
+	}
+	item.FilePath = finalPath
 	if err != nil {
-		if ctx.Err() == context.Canceled {
-			// Kill process group on cancellation
-			if cmd.Process != nil {
-				syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
-			}
-			return ctx.Err()
+		return err
+	}
+	if workDir != item.TargetDir {
+		if err := moveFile(filepath.Join(workDir, item.Filename), finalPath); err != nil {
+			return fmt.Errorf("moving download into place: %w", err)
 		}
-		// aria2c error codes: https://aria2.github.io/manual/en/html/aria2c.html#exit-status
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			switch exitErr.ExitCode() {
-			case 3:
-				return fmt.Errorf("file not found or access denied")
-			case 9:
-				return fmt.Errorf("not enough disk space available")
-			case 28:
-				return fmt.Errorf("network timeout or connection refused")
-			default:
-				return fmt.Errorf("aria2c failed with exit code %d", exitErr.ExitCode())
-			}
+	}
+	item.recordTransfer(time.Since(start))
+
+	if config.SaveMetadata {
+		if err := writeMetadata(item); err != nil && !config.Quiet {
+			fmt.Printf("%s⚠️  Could not write metadata for %s: %v%s\n", term.Yellow, item.FilePath, err, term.Reset)
 		}
-		return fmt.Errorf("aria2c execution failed: %w", err)
 	}
 
 	if !config.Quiet {
-		fmt.Printf("%s✅ Completed: %s%s\n", colorGreen, item.FilePath, colorReset)
+		fmt.Printf("%s✅ Completed: %s%s\n", term.Green, item.FilePath, term.Reset)
 	}
 
 	return nil
 }
 
-// runDownloads orchestrates single or batch downloads
-func runDownloads(ctx context.Context, urls []string, config *Config) error {
-	targetDir, err := setupDestination(config.Destination)
+// moveFile renames src to dst, copying across filesystems when a rename isn't possible
+func moveFile(src, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil || !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	in, err := os.Open(src)
 	if err != nil {
 		return err
 	}
+	defer in.Close()
 
-	// Validate all URLs first
-	for _, url := range urls {
-		if err := validateURL(url); err != nil {
-			return fmt.Errorf("invalid URL '%s': %w", url, err)
-		}
+	info, err := in.Stat()
+	if err != nil {
+		return err
 	}
 
-	// Initialize downloads
-	downloads := make([]DownloadItem, len(urls))
-	for i, url := range urls {
-		downloads[i] = DownloadItem{
-			URL: url,
-		}
+	// Copy to a temp file next to dst so a failed copy never leaves a truncated target.
+	out, err := os.CreateTemp(filepath.Dir(dst), ".dlfast-move-*")
+	if err != nil {
+		return err
 	}
+	tmpPath := out.Name()
+	defer os.Remove(tmpPath)
 
-	if !config.Quiet {
-		if len(urls) == 1 {
-			fmt.Printf("Starting download...\n")
-		} else {
-			fmt.Printf("Starting batch download of %s%d%s files...\n", colorCyan, len(urls), colorReset)
-		}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	os.Chmod(tmpPath, info.Mode().Perm())
+	os.Chtimes(tmpPath, info.ModTime(), info.ModTime()) // keep aria2c's --remote-time
+	if err := os.Rename(tmpPath, dst); err != nil {
+		return err
 	}
+	return os.Remove(src)
+}
 
-	// Download coordination
-	sem := make(chan struct{}, config.ParallelDownloads)
-	var wg sync.WaitGroup
-	errChan := make(chan error, len(urls))
+// recordTransfer stores the duration and final on-disk size of a finished download.
+// A resumed file counts in full, so its rate is an overestimate.
+func (item *DownloadItem) recordTransfer(elapsed time.Duration) {
+	item.Duration = elapsed
+	if info, err := os.Stat(item.FilePath); err == nil {
+		item.Size = info.Size()
+	}
+}
 
-	for i := range downloads {
-		wg.Add(1)
-		go func(index int) {
-			defer wg.Done()
+// printHostStats prints bytes, time and average rate per host for completed downloads
+func printHostStats(downloads []DownloadItem) {
+	stats := make(map[string]*hostStats)
+	var hosts []string
+	for _, item := range downloads {
+		if item.Duration == 0 {
+			continue
+		}
+		st, ok := stats[item.Host]
+		if !ok {
+			st = &hostStats{}
+			stats[item.Host] = st
+			hosts = append(hosts, item.Host)
+		}
+		st.Files++
+		st.Bytes += item.Size
+		st.Duration += item.Duration
+	}
+	if len(hosts) == 0 {
+		return
+	}
+	sort.Strings(hosts)
+
+	fmt.Printf("\n%sPer-host statistics:%s\n", term.Cyan, term.Reset)
+	fmt.Printf("  %-30s %5s %12s %10s %12s\n", "HOST", "FILES", "SIZE", "TIME", "AVG RATE")
+	for _, host := range hosts {
+		st := stats[host]
+		rate := float64(st.Bytes) / (1 << 20) / st.Duration.Seconds()
+		fmt.Printf("  %-30s %5d %8.1f MiB %10s %6.1f MiB/s\n", host, st.Files, float64(st.Bytes)/(1<<20), st.Duration.Round(time.Second), rate)
+	}
+}
+
+// resolveExistingFile applies --no-clobber / --interactive when the target is already on disk.
+// A file with an aria2c control file next to it is a partial download and is left to
+// resume (or restart, with --no-continue).
+func resolveExistingFile(item *DownloadItem, config *Config) error {
+	if !config.NoClobber && !config.Interactive {
+		return nil
+	}
+	if _, err := os.Stat(item.FilePath); err != nil {
+		return nil
+	}
+	if _, err := os.Stat(item.FilePath + ".aria2"); err == nil && !config.UseHTTP {
+		return nil
+	}
+
+	if config.NoClobber {
+		return errSkipped
+	}
+
+	switch promptOverwrite(item.FilePath) {
+	case "o":
+		return nil
+	case "r":
+		item.FilePath = uniqueFilePath(item.FilePath, nil)
+		item.Filename = filepath.Base(item.FilePath)
+		return nil
+	default:
+		return errSkipped
+	}
+}
+
+// promptOverwrite asks whether to overwrite, skip or rename an existing file.
+// Anything other than an explicit overwrite or rename answer counts as skip.
+func promptOverwrite(path string) string {
+	promptMu.Lock()
+	defer promptMu.Unlock()
+
+	fmt.Printf("%s⚠️  %s already exists. [o]verwrite, [s]kip, [r]ename? %s", term.Yellow, path, term.Reset)
+	answer, err := stdinReader.ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Println()
+		return "s"
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "o", "overwrite":
+		return "o"
+	case "r", "rename":
+		return "r"
+	default:
+		return "s"
+	}
+}
+
+// uniqueFilePath returns path, or path with the first free .1, .2, ... suffix if it
+// exists on disk or is in taken
+func uniqueFilePath(path string, taken map[string]bool) string {
+	free := func(p string) bool {
+		_, err := os.Stat(p)
+		return os.IsNotExist(err) && !taken[p]
+	}
+	if free(path) {
+		return path
+	}
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s.%d", path, i)
+		if free(candidate) {
+			return candidate
+		}
+	}
+}
+
+// tempSubdir names the --temp-dir subdirectory for downloads bound for dir. It
+// is derived from the path so an interrupted run finds its partial files again.
+func tempSubdir(dir string) string {
+	h := fnv.New64a()
+	h.Write([]byte(dir))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// dedupeTargets makes sure no two downloads in a batch write to the same file.
+// Later duplicates get a numeric suffix, or are rejected with --no-clobber.
+func dedupeTargets(downloads []DownloadItem, config *Config) error {
+	owner := make(map[string]string, len(downloads))
+	for i := range downloads {
+		item := &downloads[i]
+		first, dup := owner[item.FilePath]
+		if !dup {
+			owner[item.FilePath] = item.URL
+			continue
+		}
+		if config.NoClobber {
+			return fmt.Errorf("'%s' and '%s' both resolve to %s", first, item.URL, item.FilePath)
+		}
+
+		taken := make(map[string]bool, len(owner))
+		for path := range owner {
+			taken[path] = true
+		}
+		original := item.FilePath
+		item.FilePath = uniqueFilePath(item.FilePath, taken)
+		item.Filename = filepath.Base(item.FilePath)
+		owner[item.FilePath] = item.URL
+		if !config.Quiet {
+			fmt.Printf("%s⚠️  %s also resolves to %s, saving as %s%s\n", term.Yellow, item.URL, original, item.Filename, term.Reset)
+		}
+	}
+	return nil
+}
+
+// downloadWithAria2c runs aria2c for a single item
+func downloadWithAria2c(ctx context.Context, item *DownloadItem, targetDir string, config *Config) error {
+	if config.NoContinue {
+		if err := removeControlFile(targetDir, item.Filename); err != nil {
+			return err
+		}
+	}
+	_, statErr := os.Stat(filepath.Join(targetDir, item.Filename+".aria2"))
+	resuming := statErr == nil && !config.NoContinue
+	args := buildAria2cArgs(targetDir, item.Filename, item.URL, config)
+
+	cmd := exec.CommandContext(ctx, "aria2c", args...)
+
+	// Create new process group for proper signal handling
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid: true,
+	}
+	// On cancellation ask aria2c to stop so it writes its control file and the
+	// download can be resumed; only kill it if it hasn't exited after the grace period.
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+	}
+	cmd.WaitDelay = killGracePeriod
+
+	// Let aria2c output directly to terminal (unless quiet mode)
+	if !config.Quiet {
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+	} else {
+		// In quiet mode, capture stderr for error reporting
+		cmd.Stderr = os.Stderr
+	}
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.Canceled {
+			return ctx.Err()
+		}
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			code := exitErr.ExitCode()
+			if resuming && isResumeFailure(code) {
+				// The partial can't be continued; start over once rather than give up.
+				if !config.Quiet {
+					fmt.Printf("%s⚠️  Server cannot resume %s, restarting from scratch%s\n", term.Yellow, item.Filename, term.Reset)
+				}
+				fresh := *config
+				fresh.NoContinue = true
+				return downloadWithAria2c(ctx, item, targetDir, &fresh)
+			}
+			if code == 28 {
+				// A rejected option is easiest to find in the command line itself.
+				return fmt.Errorf("%s\n  aria2c %s", describeAria2Exit(code), strings.Join(redactArgs(args), " "))
+			}
+			return errors.New(describeAria2Exit(code))
+		}
+		return fmt.Errorf("aria2c execution failed: %w", err)
+	}
+
+	return nil
+}
+
+// removeControlFile deletes a stale aria2c control file so --no-continue
+// starts the transfer from scratch instead of resuming a bad partial.
+func removeControlFile(dir, filename string) error {
+	err := os.Remove(filepath.Join(dir, filename+".aria2"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("removing stale control file: %w", err)
+	}
+	return nil
+}
+
+// isResumeFailure reports whether an aria2c exit status means a partial download
+// could not be continued: 8 is an explicit "resume not supported", and 22 covers
+// unexpected responses to the range request, such as a 416.
+func isResumeFailure(code int) bool {
+	return code == 8 || code == 22
+}
 
+// describeAria2Exit turns an aria2c exit status into a user-facing explanation.
+// aria2c error codes: https://aria2.github.io/manual/en/html/aria2c.html#exit-status
+func describeAria2Exit(code int) string {
+	switch code {
+	case 1:
+		return "aria2c failed with an unknown error; rerun without --quiet to see its output"
+	case 2:
+		return "download timed out; try a larger --timeout or check your connection"
+	case 3:
+		return "file not found or access denied"
+	case 4:
+		return "server repeatedly reported the file as not found"
+	case 5:
+		return "download aborted because the speed stayed too low"
+	case 6:
+		return "network problem; check your connection and try again"
+	case 7:
+		return "download was interrupted before it finished; rerun to resume"
+	case 8:
+		return "server does not support resuming; remove the partial file and retry"
+	case 9:
+		return "not enough disk space available"
+	case 10:
+		return "piece length differs from the .aria2 control file; remove the partial file and retry"
+	case 11:
+		return "the same file is already being downloaded"
+	case 12:
+		return "a torrent with the same info hash is already being downloaded"
+	case 13:
+		return "target file already exists"
+	case 14:
+		return "could not rename the downloaded file"
+	case 15:
+		return "could not open the existing file"
+	case 16:
+		return "could not create or truncate the target file; check directory permissions"
+	case 17:
+		return "file I/O error while writing the download"
+	case 18:
+		return "could not create the target directory; check permissions"
+	case 19:
+		return "DNS name resolution failed; check the hostname and your network"
+	case 20:
+		return "could not parse the Metalink document"
+	case 21:
+		return "FTP command failed"
+	case 22:
+		return "server sent a bad or unexpected HTTP response"
+	case 23:
+		return "too many redirects"
+	case 24:
+		return "HTTP authorization failed; the URL may need cookies (--load-cookies) or credentials"
+	case 25:
+		return "could not parse the bencoded torrent file"
+	case 26:
+		return "the .torrent file is corrupted or missing information"
+	case 27:
+		return "the magnet URI is invalid"
+	case 28:
+		return "aria2c rejected an option or argument; check --max-speed and --user-agent values"
+	case 29:
+		return "server is overloaded or under maintenance; try again later"
+	case 30:
+		return "aria2c could not parse a JSON-RPC request"
+	case 32:
+		return "checksum validation failed; the file may be corrupted"
+	default:
+		return fmt.Sprintf("aria2c failed with exit code %d", code)
+	}
+}
+
+// downloadWithHTTP is the single-connection fallback used when aria2c is unavailable.
+// The body is streamed to a temp file in targetDir and renamed into place on success.
+func downloadWithHTTP(ctx context.Context, item *DownloadItem, targetDir string, config *Config) error {
+	client := newDownloadClient(config)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", item.URL, nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+
+	setRequestHeaders(req, config)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("HTTP GET request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server returned %s", resp.Status)
+	}
+
+	tmpFile, err := os.CreateTemp(targetDir, ".dlfast-"+item.Filename+"-*.part")
+	if err != nil {
+		return fmt.Errorf("creating temp file: %w", err)
+	}
+	tmpPath := tmpFile.Name()
+
+	// Response body reads fail once ctx is cancelled, so io.Copy stops promptly
+	_, copyErr := io.Copy(tmpFile, resp.Body)
+	closeErr := tmpFile.Close()
+
+	if copyErr != nil || closeErr != nil {
+		os.Remove(tmpPath)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if copyErr != nil {
+			return fmt.Errorf("writing download: %w", copyErr)
+		}
+		return fmt.Errorf("closing temp file: %w", closeErr)
+	}
+
+	if err := os.Rename(tmpPath, item.FilePath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("moving download into place: %w", err)
+	}
+
+	return nil
+}
+
+// newDownloadClient builds the client for single-connection GET downloads
+func newDownloadClient(config *Config) *http.Client {
+	return &http.Client{
+		Jar:           config.CookieJar,
+		CheckRedirect: redirectLimit(config.MaxRedirects),
+		Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           dialPreferring(config.IPVersion),
+			TLSHandshakeTimeout:   time.Duration(config.ConnectTimeout) * time.Second,
+			ResponseHeaderTimeout: time.Duration(config.Timeout) * time.Second,
+		},
+	}
+}
+
+// streamToStdout writes one download to stdout for -O -. aria2c can't write a
+// segmented download to a pipe, so this is a single streamed GET; every
+// message goes to stderr to keep stdout clean.
+func streamToStdout(ctx context.Context, rawURL string, config *Config) error {
+	if err := validateURL(rawURL); err != nil {
+		return fmt.Errorf("invalid URL '%s': %w", rawURL, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	setRequestHeaders(req, config)
+
+	resp, err := newDownloadClient(config).Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("HTTP GET request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server returned %s", resp.Status)
+	}
+
+	var written atomic.Int64
+	if !config.Quiet {
+		done := make(chan struct{})
+		defer func() {
+			close(done)
+			fmt.Fprintf(os.Stderr, "\r⬇️  %.1f MiB written to stdout\033[K\n", float64(written.Load())/(1<<20))
+		}()
+		go func() {
+			ticker := time.NewTicker(500 * time.Millisecond)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+					if resp.ContentLength > 0 {
+						fmt.Fprintf(os.Stderr, "\r⬇️  %.1f of %.1f MiB\033[K", float64(written.Load())/(1<<20), float64(resp.ContentLength)/(1<<20))
+					} else {
+						fmt.Fprintf(os.Stderr, "\r⬇️  %.1f MiB\033[K", float64(written.Load())/(1<<20))
+					}
+				}
+			}
+		}()
+	}
+
+	// Response body reads fail once ctx is cancelled, so io.Copy stops promptly
+	if _, err := io.Copy(io.MultiWriter(os.Stdout, byteCounter{&written}), resp.Body); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("streaming to stdout: %w", err)
+	}
+	return nil
+}
+
+// byteCounter is an io.Writer that only counts what passes through it
+type byteCounter struct {
+	n *atomic.Int64
+}
+
+func (c byteCounter) Write(p []byte) (int, error) {
+	c.n.Add(int64(len(p)))
+	return len(p), nil
+}
+
+// runSpeedtest downloads from rawURL for speedtestDuration over as many ranged
+// connections as aria2c would open, discards the data and reports the rate.
+func runSpeedtest(ctx context.Context, rawURL string, config *Config) error {
+	if err := validateURL(rawURL); err != nil {
+		return err
+	}
+	remote, err := detectFilename(ctx, rawURL, config)
+	if err != nil {
+		return err
+	}
+	conns := int64(maxConnectionsPerServer)
+	if remote.ContentLength < conns {
+		conns = 1 // size unknown (or tiny), so the file can't be split
+	}
+	chunk := max(remote.ContentLength/conns, 1)
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialPreferring(config.IPVersion)
+	transport.MaxConnsPerHost = int(conns)
+	client := &http.Client{Jar: config.CookieJar, Transport: transport, CheckRedirect: redirectLimit(config.MaxRedirects)}
+
+	testCtx, cancel := context.WithTimeout(ctx, speedtestDuration)
+	defer cancel()
+
+	var total atomic.Int64
+	var noRanges atomic.Bool
+	var wg sync.WaitGroup
+	errChan := make(chan error, conns)
+	start := time.Now()
+
+	for i := range conns {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			req, err := http.NewRequestWithContext(testCtx, "GET", rawURL, nil)
+			if err != nil {
+				errChan <- err
+				return
+			}
+			setRequestHeaders(req, config)
+			if conns > 1 {
+				req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", i*chunk, (i+1)*chunk-1))
+			}
+
+			resp, err := client.Do(req)
+			if err != nil {
+				if testCtx.Err() == nil {
+					errChan <- err
+				}
+				return
+			}
+			defer resp.Body.Close()
+
+			switch {
+			case resp.StatusCode == http.StatusPartialContent:
+			case resp.StatusCode == http.StatusOK:
+				// The server ignored Range and sends the whole file on every
+				// connection; only measure the first one.
+				if conns > 1 {
+					noRanges.Store(true)
+					if i > 0 {
+						return
+					}
+				}
+			default:
+				errChan <- fmt.Errorf("server returned %s", resp.Status)
+				return
+			}
+
+			// Reads fail once testCtx expires; the bytes counted so far are the result.
+			io.Copy(byteCounter{&total}, resp.Body)
+		}()
+	}
+
+	wg.Wait()
+	elapsed := time.Since(start)
+	close(errChan)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if total.Load() == 0 {
+		if err := <-errChan; err != nil {
+			return err
+		}
+		return errors.New("no data received")
+	}
+
+	used := conns
+	if noRanges.Load() {
+		used = 1
+		fmt.Printf("%s⚠️  Server ignores range requests, so aria2c can only use one connection%s\n", term.Yellow, term.Reset)
+	}
+	rate := float64(total.Load()) / elapsed.Seconds()
+	fmt.Printf("%s%s%s: %.1f MiB in %.1fs over %d connection(s) = %s%.2f MiB/s%s (%.1f Mbit/s)\n",
+		term.Cyan, rawURL, term.Reset, float64(total.Load())/(1<<20), elapsed.Seconds(), used,
+		term.Bold, rate/(1<<20), term.Reset, rate*8/1e6)
+	return nil
+}
+
+// writeMetadata writes a <filename>.meta.json sidecar next to a finished download
+func writeMetadata(item *DownloadItem) error {
+	info, err := os.Stat(item.FilePath)
+	if err != nil {
+		return fmt.Errorf("reading downloaded file: %w", err)
+	}
+
+	meta := DownloadMetadata{
+		SourceURL:    item.URL,
+		DownloadedAt: time.Now().UTC(),
+		Size:         info.Size(),
+	}
+	if item.Remote != nil {
+		meta.FinalURL = item.Remote.FinalURL
+		meta.ContentType = item.Remote.ContentType
+		meta.ETag = item.Remote.ETag
+		meta.LastModified = item.Remote.LastModified
+	}
+
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding metadata: %w", err)
+	}
+
+	return os.WriteFile(item.FilePath+".meta.json", append(data, '\n'), 0644)
+}
+
+// runDownloads orchestrates single or batch downloads
+func runDownloads(ctx context.Context, jobs []downloadJob, config *Config) (err error) {
+	targetDir, err := setupDestination(config.Destination)
+	if err != nil {
+		return err
+	}
+
+	// Batches keep a state file so an interrupted run can be resumed.
+	var state *batchState
+	if len(jobs) > 1 || config.ResumeBatch {
+		state = newBatchState(targetDir)
+		if config.ResumeBatch {
+			if err := state.load(); err != nil {
+				return fmt.Errorf("loading batch state: %w", err)
+			}
+			if jobs = state.pending(jobs, config); len(jobs) == 0 {
+				os.Remove(state.path)
+				return nil
+			}
+		}
+		defer func() {
+			if err == nil {
+				os.Remove(state.path)
+			}
+		}()
+	}
+
+	if config.TempDir != "" {
+		if config.TempDir, err = filepath.Abs(config.TempDir); err != nil {
+			return fmt.Errorf("resolving --temp-dir: %w", err)
+		}
+		if err := os.MkdirAll(config.TempDir, 0755); err != nil {
+			return fmt.Errorf("creating --temp-dir '%s': %w", config.TempDir, err)
+		}
+	}
+
+	// Validate all URLs first. With --skip-invalid a bad entry is reported as
+	// a failure at the end instead of stopping the batch before it starts.
+	var invalidErrors []error
+	var validJobs []downloadJob
+	for _, job := range jobs {
+		if err := validateURL(job.URL); err != nil {
+			err = fmt.Errorf("invalid URL '%s': %w", job.URL, err)
+			if !config.SkipInvalid {
+				return err
+			}
+			if config.ProgressJSON {
+				emitEvent(progressEvent{Event: "error", URL: job.URL, Error: err.Error()})
+			}
+			if !config.Quiet {
+				fmt.Printf("%s⚠️  Skipping %v%s\n", term.Yellow, err, term.Reset)
+			}
+			invalidErrors = append(invalidErrors, err)
+			continue
+		}
+		validJobs = append(validJobs, job)
+	}
+	if jobs = validJobs; len(jobs) == 0 {
+		return fmt.Errorf("no valid URLs: %v", invalidErrors)
+	}
+
+	// Only count slots that will be used, now that skipped and already
+	// finished URLs are gone; --max-total-speed is split across them.
+	config.ParallelDownloads = min(config.ParallelDownloads, len(jobs))
+
+	// Initialize downloads, preparing each per-URL directory once
+	dirs := map[string]string{"": targetDir}
+	downloads := make([]DownloadItem, len(jobs))
+	for i, job := range jobs {
+		dir, ok := dirs[job.Dir]
+		if !ok {
+			// A per-URL destination is always a directory, created if missing.
+			if dir, err = setupDestination(job.Dir + string(filepath.Separator)); err != nil {
+				return fmt.Errorf("destination for %s: %w", job.URL, err)
+			}
+			dirs[job.Dir] = dir
+		}
+		destDir, sub := dir, ""
+		if config.MirrorPath {
+			if sub = mirrorSubdir(job.URL, config.CutDirs); sub != "" {
+				dir = filepath.Join(dir, sub)
+				if err := os.MkdirAll(dir, 0755); err != nil {
+					return fmt.Errorf("creating directory '%s': %w", dir, err)
+				}
+			}
+		}
+		workDir := dir
+		if config.TempDir != "" {
+			// One subdirectory per destination (plus the mirrored path), so
+			// equal filenames bound for different directories don't share a
+			// partial file.
+			workDir = filepath.Join(config.TempDir, tempSubdir(destDir), sub)
+			if err := os.MkdirAll(workDir, 0755); err != nil {
+				return fmt.Errorf("creating directory '%s': %w", workDir, err)
+			}
+		}
+		downloads[i] = DownloadItem{
+			URL:       job.URL,
+			TargetDir: dir,
+			WorkDir:   workDir,
+		}
+		if u, err := url.Parse(job.URL); err == nil {
+			downloads[i].Host = u.Host
+		}
+	}
+
+	resolveAllFilenames(ctx, downloads, config)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := dedupeTargets(downloads, config); err != nil {
+		return err
+	}
+
+	if !config.Quiet {
+		if len(jobs) == 1 {
+			fmt.Printf("Starting download...\n")
+		} else {
+			fmt.Printf("Starting batch download of %s%d%s files...\n", term.Cyan, len(jobs), term.Reset)
+		}
+	}
+
+	if config.UseRPC && !config.UseHTTP {
+		err := runRPCDownloads(ctx, downloads, config, state)
+		if config.Stats {
+			printHostStats(downloads)
+		}
+		if err == nil && len(invalidErrors) > 0 {
+			return fmt.Errorf("some downloads failed: %v", invalidErrors)
+		}
+		return err
+	}
+
+	// Download coordination. With --fail-fast the first failure cancels runCtx,
+	// which stops in-flight downloads and keeps queued ones from starting.
+	runCtx, cancelRun := context.WithCancel(ctx)
+	defer cancelRun()
+	var firstErr error
+	var firstErrOnce sync.Once
+
+	sem := make(chan struct{}, config.ParallelDownloads)
+	var wg sync.WaitGroup
+	errChan := make(chan error, len(jobs))
+
+	// Per-host slots are taken before a global one, so a download waiting on a
+	// busy host never holds a slot another host could use.
+	hostSems := make(map[string]chan struct{})
+	if config.PerHostLimit > 0 {
+		for _, item := range downloads {
+			if _, ok := hostSems[item.Host]; !ok {
+				hostSems[item.Host] = make(chan struct{}, config.PerHostLimit)
+			}
+		}
+	}
+
+	for i := range downloads {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+
+			if hostSem := hostSems[downloads[index].Host]; hostSem != nil {
+				hostSem <- struct{}{}
+				defer func() { <-hostSem }()
+			}
 			sem <- struct{}{}        // Acquire semaphore
 			defer func() { <-sem }() // Release semaphore
 
-			if !config.Quiet && len(urls) > 1 {
-				fmt.Printf("\n[%s%d%s/%s%d%s] ", colorCyan, index+1, colorReset, colorCyan, len(urls), colorReset)
+			if runCtx.Err() != nil && ctx.Err() == nil {
+				return // aborted by --fail-fast before this one started
 			}
 
-			if err := downloadFile(ctx, &downloads[index], targetDir, config); err != nil {
+			if !config.Quiet && len(jobs) > 1 {
+				fmt.Printf("\n[%s%d%s/%s%d%s] ", term.Cyan, index+1, term.Reset, term.Cyan, len(jobs), term.Reset)
+			}
+
+			if err := downloadFile(runCtx, &downloads[index], config); err != nil {
 				if errors.Is(err, context.Canceled) {
 					if !config.Quiet {
-						fmt.Printf("%s❌ Cancelled: %s%s\n", colorRed, downloads[index].URL, colorReset)
+						fmt.Printf("%s❌ Cancelled: %s%s\n", term.Red, downloads[index].URL, term.Reset)
+					}
+				} else if errors.Is(err, errSkipped) {
+					if !config.Quiet {
+						fmt.Printf("%s⏭️  Skipped existing file: %s%s\n", term.Yellow, downloads[index].FilePath, term.Reset)
 					}
 				} else {
 					if !config.Quiet {
-						fmt.Printf("%s❌ Failed: %s - %v%s\n", colorRed, downloads[index].URL, err, colorReset)
+						fmt.Printf("%s❌ Failed: %s - %v%s\n", term.Red, downloads[index].URL, err, term.Reset)
+					}
+					err = fmt.Errorf("download %d failed: %w", index+1, err)
+					errChan <- err
+					if config.FailFast {
+						firstErrOnce.Do(func() {
+							firstErr = err
+							cancelRun()
+						})
 					}
-					errChan <- fmt.Errorf("download %d failed: %w", index+1, err)
 				}
 				return
 			}
+			if err := state.markDone(&downloads[index]); err != nil && !config.Quiet {
+				fmt.Printf("%s⚠️  Could not update %s: %v%s\n", term.Yellow, batchStateFile, err, term.Reset)
+			}
 		}(i)
 	}
 
@@ -438,19 +1747,503 @@ func runDownloads(ctx context.Context, urls []string, config *Config) error {
 	close(errChan)
 
 	// Check for errors
-	var downloadErrors []error
+	downloadErrors := invalidErrors
 	for err := range errChan {
 		downloadErrors = append(downloadErrors, err)
 	}
 
+	if config.Stats {
+		printHostStats(downloads)
+	}
+
 	if ctx.Err() == context.Canceled {
-		return fmt.Errorf("downloads cancelled by user")
+		return fmt.Errorf("downloads cancelled by user: %w", ctx.Err())
+	}
+
+	if firstErr != nil {
+		return fmt.Errorf("aborting batch (--fail-fast): %w", firstErr)
+	}
+
+	if len(downloadErrors) > 0 {
+		return fmt.Errorf("some downloads failed: %v", downloadErrors)
+	}
+
+	return nil
+}
+
+// aria2RPC is a minimal JSON-RPC client for an aria2c daemon started by dlfast
+type aria2RPC struct {
+	endpoint string
+	secret   string
+	client   *http.Client
+}
+
+// aria2Status is the subset of aria2.tellStatus fields dlfast uses; aria2 reports numbers as strings
+type aria2Status struct {
+	Status          string `json:"status"`
+	TotalLength     string `json:"totalLength"`
+	CompletedLength string `json:"completedLength"`
+	DownloadSpeed   string `json:"downloadSpeed"`
+	ErrorCode       string `json:"errorCode"`
+	ErrorMessage    string `json:"errorMessage"`
+}
+
+// call invokes an aria2 RPC method, prepending the secret token, and decodes the result into result
+func (r *aria2RPC) call(ctx context.Context, method string, result interface{}, params ...interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      "dlfast",
+		"method":  method,
+		"params":  append([]interface{}{"token:" + r.secret}, params...),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", r.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var reply struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return fmt.Errorf("%s: decoding response: %w", method, err)
+	}
+	if reply.Error != nil {
+		return fmt.Errorf("%s: %s (code %d)", method, reply.Error.Message, reply.Error.Code)
+	}
+	if result != nil {
+		return json.Unmarshal(reply.Result, result)
+	}
+	return nil
+}
+
+// startAria2Daemon launches aria2c with RPC enabled on a free loopback port and waits until it answers
+func startAria2Daemon(ctx context.Context, config *Config) (*exec.Cmd, *aria2RPC, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, nil, fmt.Errorf("finding a free port: %w", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, nil, fmt.Errorf("generating RPC secret: %w", err)
+	}
+
+	args := []string{
+		"--enable-rpc=true",
+		"--rpc-listen-all=false",
+		"--rpc-listen-port=" + strconv.Itoa(port),
+		"--rpc-secret=" + hex.EncodeToString(token),
+		"--stop-with-process=" + strconv.Itoa(os.Getpid()),
+		"--max-concurrent-downloads=" + strconv.Itoa(max(config.ParallelDownloads, 1)),
+	}
+	args = append(args, aria2cTransferArgs(config)...)
+	if config.MaxSpeed != "" {
+		args = append(args, "--max-download-limit="+config.MaxSpeed)
+	}
+	if config.MaxTotalSpeed != "" {
+		args = append(args, "--max-overall-download-limit="+config.MaxTotalSpeed)
+	}
+
+	cmd := exec.Command("aria2c", args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return nil, nil, fmt.Errorf("starting aria2c daemon: %w", err)
+	}
+
+	rpc := &aria2RPC{
+		endpoint: fmt.Sprintf("http://127.0.0.1:%d/jsonrpc", port),
+		secret:   hex.EncodeToString(token),
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+
+	// The daemon needs a moment before it accepts connections.
+	deadline := time.Now().Add(rpcStartupTimeout)
+	for {
+		err := rpc.call(ctx, "aria2.getVersion", nil)
+		if err == nil {
+			return cmd, rpc, nil
+		}
+		if ctx.Err() != nil || time.Now().After(deadline) {
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+			cmd.Wait()
+			if ctx.Err() != nil {
+				return nil, nil, ctx.Err()
+			}
+			return nil, nil, fmt.Errorf("aria2c daemon did not start: %v %s", err, strings.TrimSpace(stderr.String()))
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// progressEvent is one line of --progress-json output. Event is "progress",
+// "complete", "skipped" or "error"; a run-level error has no URL.
+type progressEvent struct {
+	Event     string `json:"event"`
+	URL       string `json:"url,omitempty"`
+	Filename  string `json:"filename,omitempty"`
+	Completed int64  `json:"completed"`
+	Total     int64  `json:"total"`
+	Speed     int64  `json:"speed"`
+	Error     string `json:"error,omitempty"`
+}
+
+// emitEvent writes ev as a single JSON line on stdout
+func emitEvent(ev progressEvent) {
+	data, _ := json.Marshal(ev)
+	fmt.Printf("%s\n", data)
+}
+
+// autoParallelism picks the --auto-parallel concurrency: one download per CPU
+// (at least 2, at most autoParallelMax), no more than perHost per distinct
+// host, and never more than there are URLs.
+func autoParallelism(jobs []downloadJob, perHost int) int {
+	hosts := make(map[string]struct{})
+	for _, job := range jobs {
+		if u, err := url.Parse(job.URL); err == nil {
+			hosts[u.Host] = struct{}{}
+		}
+	}
+	n := min(max(runtime.NumCPU(), 2), autoParallelMax)
+	n = min(n, max(len(hosts), 1)*perHost, len(jobs))
+	return max(n, 1)
+}
+
+// runSummary is the end-of-run result saved by --summary-file
+type runSummary struct {
+	Status   string    `json:"status"` // "ok", "failed" or "cancelled"
+	URLs     int       `json:"urls"`
+	Message  string    `json:"message"`
+	Finished time.Time `json:"finished"`
+}
+
+// writeSummary saves summary to path, as JSON with --progress-json and as
+// plain text otherwise
+func writeSummary(path string, summary runSummary, asJSON bool) error {
+	var data []byte
+	if asJSON {
+		var err error
+		if data, err = json.MarshalIndent(summary, "", "  "); err != nil {
+			return err
+		}
+		data = append(data, '\n')
+	} else {
+		data = fmt.Appendf(nil, "dlfast run finished %s\nURLs: %d\nStatus: %s\n%s\n",
+			summary.Finished.Format(time.RFC3339), summary.URLs, summary.Status, summary.Message)
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// progressTracker estimates the time left for a whole batch from the HEAD
+// Content-Length of each file and a moving average of the aggregate rate.
+type progressTracker struct {
+	totalBytes int64 // bytes still owed by unfinished downloads, -1 if any size is unknown
+	avgRate    float64
+	sampled    bool
+}
+
+// etaSmoothing is the weight of the newest rate sample in the moving average
+const etaSmoothing = 0.3
+
+func newProgressTracker(items []*DownloadItem) *progressTracker {
+	p := &progressTracker{}
+	for _, item := range items {
+		if item.Remote == nil || item.Remote.ContentLength < 0 {
+			p.totalBytes = -1
+			break
+		}
+		p.totalBytes += item.Remote.ContentLength
+	}
+	return p
+}
+
+// finish drops a completed or failed download from the remaining total.
+func (p *progressTracker) finish(item *DownloadItem) {
+	if p.totalBytes >= 0 {
+		p.totalBytes -= item.Remote.ContentLength
+	}
+}
+
+// addSample feeds the current aggregate rate in bytes per second.
+func (p *progressTracker) addSample(rate int64) {
+	if !p.sampled {
+		if rate <= 0 {
+			return
+		}
+		p.avgRate, p.sampled = float64(rate), true
+		return
+	}
+	p.avgRate = etaSmoothing*float64(rate) + (1-etaSmoothing)*p.avgRate
+}
+
+// eta formats the estimated time left given the bytes already fetched by
+// unfinished downloads, or "--:--" while it cannot be estimated.
+func (p *progressTracker) eta(activeDone int64) string {
+	if !p.sampled || p.avgRate < 1 || p.totalBytes < 0 {
+		return "--:--"
+	}
+	remaining := max(p.totalBytes-activeDone, 0)
+	secs := int64(float64(remaining) / p.avgRate)
+	if secs >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", secs/3600, secs/60%60, secs%60)
+	}
+	return fmt.Sprintf("%02d:%02d", secs/60, secs%60)
+}
+
+// runRPCDownloads submits every download to a single aria2c daemon and polls it
+// until all have finished, so connection and speed limits apply to the whole batch
+func runRPCDownloads(ctx context.Context, downloads []DownloadItem, config *Config, state *batchState) error {
+	// Existing-file prompts happen before anything starts so they don't fight the progress line.
+	gids := make(map[string]*DownloadItem)
+	started := make(map[string]time.Time) // first poll that saw the GID active
+	var pending []*DownloadItem
+	for i := range downloads {
+		item := &downloads[i]
+		if err := resolveExistingFile(item, config); err != nil {
+			if errors.Is(err, errSkipped) && !config.Quiet {
+				fmt.Printf("%s⏭️  Skipped existing file: %s%s\n", term.Yellow, item.FilePath, term.Reset)
+			}
+			if config.ProgressJSON {
+				emitEvent(progressEvent{Event: "skipped", URL: item.URL, Filename: item.Filename})
+			}
+			continue
+		}
+		pending = append(pending, item)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	cmd, rpc, err := startAria2Daemon(ctx, config)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// ctx may already be cancelled, so shut the daemon down on a fresh one.
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if rpc.call(shutdownCtx, "aria2.forceShutdown", nil) != nil {
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+		}
+		cmd.Wait()
+	}()
+
+	for _, item := range pending {
+		workDir := item.WorkDir
+		if config.NoContinue {
+			if err := removeControlFile(workDir, item.Filename); err != nil {
+				return fmt.Errorf("%s: %w", item.URL, err)
+			}
+		}
+		options := map[string]string{"dir": workDir, "out": item.Filename}
+		var gid string
+		if err := rpc.call(ctx, "aria2.addUri", &gid, []string{item.URL}, options); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("queueing %s: %w", item.URL, err)
+		}
+		gids[gid] = item
+		if !config.Quiet {
+			fmt.Printf("📥 Queued: %s%s%s → %s%s%s\n", term.Cyan, item.URL, term.Reset, term.Cyan, item.FilePath, term.Reset)
+		}
+	}
+
+	tracker := newProgressTracker(pending)
+	var downloadErrors []error
+	keys := []string{"status", "totalLength", "completedLength", "downloadSpeed", "errorCode", "errorMessage"}
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	// Transfer time for --stats runs from when a download leaves the daemon's
+	// queue, not from when it was added.
+	lastPoll := time.Now()
+	for len(gids) > 0 {
+		select {
+		case <-ctx.Done():
+			if !config.Quiet {
+				fmt.Println()
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		pollStart := time.Now()
+
+		var done, total, speed int64
+		for gid, item := range gids {
+			var st aria2Status
+			if err := rpc.call(ctx, "aria2.tellStatus", &st, gid, keys); err != nil {
+				if ctx.Err() != nil {
+					break
+				}
+				return fmt.Errorf("polling aria2c: %w", err)
+			}
+
+			switch st.Status {
+			case "complete":
+				delete(gids, gid)
+				tracker.finish(item)
+				if item.WorkDir != item.TargetDir {
+					if err := moveFile(filepath.Join(item.WorkDir, item.Filename), item.FilePath); err != nil {
+						err = fmt.Errorf("moving download into place: %w", err)
+						if !config.Quiet {
+							fmt.Printf("\r%s❌ Failed: %s - %v%s\033[K\n", term.Red, item.URL, err, term.Reset)
+						}
+						if config.ProgressJSON {
+							emitEvent(progressEvent{Event: "error", URL: item.URL, Filename: item.Filename, Error: err.Error()})
+						}
+						downloadErrors = append(downloadErrors, fmt.Errorf("%s: %w", item.URL, err))
+						continue
+					}
+				}
+				begin, ok := started[gid]
+				if !ok {
+					begin = lastPoll // finished between two polls
+				}
+				item.recordTransfer(time.Since(begin))
+				if config.SaveMetadata {
+					if err := writeMetadata(item); err != nil && !config.Quiet {
+						fmt.Printf("\r%s⚠️  Could not write metadata for %s: %v%s\n", term.Yellow, item.FilePath, err, term.Reset)
+					}
+				}
+				if !config.Quiet {
+					fmt.Printf("\r%s✅ Completed: %s%s\033[K\n", term.Green, item.FilePath, term.Reset)
+				}
+				if config.ProgressJSON {
+					emitEvent(progressEvent{Event: "complete", URL: item.URL, Filename: item.Filename, Completed: item.Size, Total: item.Size})
+				}
+				if err := state.markDone(item); err != nil && !config.Quiet {
+					fmt.Printf("%s⚠️  Could not update %s: %v%s\n", term.Yellow, batchStateFile, err, term.Reset)
+				}
+			case "error", "removed":
+				delete(gids, gid)
+				tracker.finish(item)
+				code, _ := strconv.Atoi(st.ErrorCode)
+				err := errors.New(describeAria2Exit(code))
+				if !config.Quiet {
+					fmt.Printf("\r%s❌ Failed: %s - %v%s\033[K\n", term.Red, item.URL, err, term.Reset)
+				}
+				if config.ProgressJSON {
+					emitEvent(progressEvent{Event: "error", URL: item.URL, Filename: item.Filename, Error: err.Error()})
+				}
+				downloadErrors = append(downloadErrors, fmt.Errorf("%s: %w", item.URL, err))
+				if config.FailFast {
+					return fmt.Errorf("aborting batch (--fail-fast): %w", downloadErrors[0])
+				}
+			default:
+				completed, _ := strconv.ParseInt(st.CompletedLength, 10, 64)
+				length, _ := strconv.ParseInt(st.TotalLength, 10, 64)
+				rate, _ := strconv.ParseInt(st.DownloadSpeed, 10, 64)
+				done += completed
+				total += length
+				speed += rate
+				if _, ok := started[gid]; !ok && st.Status == "active" {
+					started[gid] = pollStart
+				}
+				if config.ProgressJSON && st.Status == "active" {
+					emitEvent(progressEvent{Event: "progress", URL: item.URL, Filename: item.Filename, Completed: completed, Total: length, Speed: rate})
+				}
+			}
+		}
+
+		lastPoll = pollStart
+		tracker.addSample(speed)
+		if !config.Quiet && len(gids) > 0 {
+			progress := "?"
+			if total > 0 {
+				progress = fmt.Sprintf("%d%%", done*100/total)
+			}
+			fmt.Printf("\r⬇️  %d active, %s of %.1f MiB at %.1f MiB/s, ETA %s\033[K", len(gids), progress, float64(total)/(1<<20), float64(speed)/(1<<20), tracker.eta(done))
+		}
 	}
 
 	if len(downloadErrors) > 0 {
 		return fmt.Errorf("some downloads failed: %v", downloadErrors)
 	}
+	return nil
+}
 
+// writeCompletion prints a completion script for the given shell, built from
+// the registered command-line flags.
+func writeCompletion(w io.Writer, shell string) error {
+	type flagInfo struct {
+		name, usage string
+		takesValue  bool
+	}
+	var flags []flagInfo
+	flag.VisitAll(func(f *flag.Flag) {
+		isBool := false
+		if bf, ok := f.Value.(interface{ IsBoolFlag() bool }); ok {
+			isBool = bf.IsBoolFlag()
+		}
+		flags = append(flags, flagInfo{name: f.Name, usage: f.Usage, takesValue: !isBool})
+	})
+
+	// Single-letter flags are written -d, the rest --name.
+	dashed := func(name string) string {
+		if len(name) == 1 {
+			return "-" + name
+		}
+		return "--" + name
+	}
+
+	switch shell {
+	case "bash":
+		var names []string
+		for _, f := range flags {
+			names = append(names, dashed(f.name))
+		}
+		fmt.Fprintf(w, "_dlfast() {\n")
+		fmt.Fprintf(w, "    local cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+		fmt.Fprintf(w, "    if [[ \"$cur\" == -* ]]; then\n")
+		fmt.Fprintf(w, "        COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n", strings.Join(names, " "))
+		fmt.Fprintf(w, "    fi\n")
+		fmt.Fprintf(w, "}\n")
+		fmt.Fprintf(w, "complete -o default -F _dlfast dlfast\n")
+	case "zsh":
+		escape := strings.NewReplacer("'", "'\\''", "[", "\\[", "]", "\\]")
+		fmt.Fprintf(w, "#compdef dlfast\n\n_arguments \\\n")
+		for _, f := range flags {
+			spec := fmt.Sprintf("%s[%s]", dashed(f.name), escape.Replace(f.usage))
+			if f.takesValue {
+				spec += ":value:"
+			}
+			fmt.Fprintf(w, "  '%s' \\\n", spec)
+		}
+		fmt.Fprintf(w, "  '*:url:_urls'\n")
+	case "fish":
+		escape := strings.NewReplacer("\\", "\\\\", "'", "\\'")
+		for _, f := range flags {
+			opt := "-l " + f.name
+			if len(f.name) == 1 {
+				opt = "-s " + f.name
+			}
+			if f.takesValue {
+				opt += " -r"
+			}
+			fmt.Fprintf(w, "complete -c dlfast %s -d '%s'\n", opt, escape.Replace(f.usage))
+		}
+	default:
+		return fmt.Errorf("unsupported shell %q (want bash, zsh or fish)", shell)
+	}
 	return nil
 }
 
@@ -465,46 +2258,234 @@ func main() {
 
 	flag.StringVar(&config.Destination, "d", "", "Target directory for downloads")
 	flag.StringVar(&config.MaxSpeed, "max-speed", "", "Maximum download speed (e.g., 1M, 500K)")
+	flag.StringVar(&config.MaxTotalSpeed, "max-total-speed", "", "Maximum combined speed of all parallel downloads (e.g., 4M)")
 	flag.IntVar(&config.Timeout, "timeout", defaultTimeout, "Download timeout in seconds")
 	flag.IntVar(&config.ConnectTimeout, "connect-timeout", defaultConnectTimeout, "Connection timeout in seconds")
 	flag.IntVar(&config.MaxTries, "max-tries", defaultMaxTries, "Maximum retry attempts")
 	flag.IntVar(&config.RetryWait, "retry-wait", defaultRetryWait, "Wait time between retries in seconds")
 	flag.StringVar(&config.UserAgent, "user-agent", "", "Custom User-Agent string")
+	var browserUA bool
+	flag.BoolVar(&browserUA, "browser-ua", false, "Send a desktop browser User-Agent instead of dlfast/1.0, for sites that block unknown clients")
+	flag.IntVar(&config.MaxRedirects, "max-redirects", defaultMaxRedirects, "Maximum redirects to follow when resolving filenames and in built-in HTTP downloads")
+	flag.StringVar(&config.Referer, "referer", "", "Referer header to send (some CDNs require it)")
+	flag.IntVar(&config.IPVersion, "ip-version", 0, "Prefer IPv4 (4) or IPv6 (6) connections")
 	flag.IntVar(&config.ParallelDownloads, "parallel", defaultParallelDownloads, "Number of parallel downloads (batch mode)")
+	var autoParallel bool
+	flag.BoolVar(&autoParallel, "auto-parallel", false, "Pick the number of parallel downloads from the CPU count and URLs, with at most 2 per host")
+	flag.IntVar(&config.PerHostLimit, "per-host", 0, "Maximum parallel downloads from the same host, on top of --parallel (0 = no limit)")
 	flag.BoolVar(&config.Quiet, "quiet", false, "Suppress progress display")
+	flag.StringVar(&config.HTTPUser, "http-user", "", "Username for HTTP basic authentication")
+	flag.StringVar(&config.HTTPPasswd, "http-passwd", "", "Password for HTTP basic authentication")
+	flag.StringVar(&config.BearerToken, "bearer", "", "Send an 'Authorization: Bearer <token>' header")
+	flag.StringVar(&config.CookieFile, "load-cookies", "", "Load cookies from a Netscape-format cookie file")
+	flag.BoolVar(&config.NoClobber, "no-clobber", false, "Skip downloads whose target file already exists")
+	flag.BoolVar(&config.Interactive, "interactive", false, "Ask before overwriting an existing file (overwrite, skip or rename)")
+	flag.BoolVar(&config.AllowFallback, "allow-fallback", false, "Use a built-in single-connection downloader if aria2c is not installed")
+	flag.BoolVar(&config.MirrorPath, "mirror-path", false, "Recreate the URL's directory path under the target directory")
+	flag.IntVar(&config.CutDirs, "cut-dirs", 0, "With --mirror-path, drop this many leading directories from the URL path")
+	flag.StringVar(&config.TempDir, "temp-dir", "", "Download into this directory and move finished files to the target (e.g., a fast local disk)")
+	flag.BoolVar(&config.NoContinue, "no-continue", false, "Restart downloads from scratch instead of resuming partial files")
+	flag.BoolVar(&config.NoIntegrityCheck, "no-integrity-check", false, "Skip re-hashing resumed data (faster, less safe)")
+	flag.BoolVar(&config.ResumeBatch, "resume-batch", false, "Skip URLs an interrupted earlier batch already finished (tracked in "+batchStateFile+" in -d)")
+	flag.BoolVar(&config.SkipInvalid, "skip-invalid", false, "Skip invalid URLs in a batch (reported as failures at the end) instead of aborting")
+	flag.BoolVar(&config.FailFast, "fail-fast", false, "Stop the whole batch as soon as one download fails")
+	flag.BoolVar(&config.Stats, "stats", false, "Print bytes, time and average rate per host after the run")
+	flag.BoolVar(&config.GuessExtension, "guess-extension", false, "Add an extension from the Content-Type when the filename has none")
+	flag.StringVar(&config.SummaryFile, "summary-file", "", "Also write the final success/failure summary to this file (JSON with --progress-json)")
+	flag.BoolVar(&config.ProgressJSON, "progress-json", false, "Print progress, completion and errors as JSON lines on stdout instead of human output (implies --rpc)")
+	flag.BoolVar(&config.UseRPC, "rpc", false, "Run the whole batch through one aria2c daemon (global connection and speed limits, single progress line)")
+	flag.BoolVar(&config.SaveMetadata, "save-metadata", false, "Write a <filename>.meta.json sidecar with source URL and server headers")
+	var inputFile string
+	flag.StringVar(&config.OutputFile, "O", "", "Use '-' to stream a single download to stdout (one connection, no aria2c)")
+	var speedtest bool
+	flag.BoolVar(&speedtest, "speedtest", false, "Measure download speed from each URL for a few seconds without saving anything")
+	flag.StringVar(&inputFile, "i", "", "Read URLs from a file, one per line (- for stdin); a line may add a tab or | and a destination directory")
+	var colorMode string
+	var showVersion bool
+	flag.StringVar(&colorMode, "color", term.ColorAuto, "Colorize output: auto, always or never")
+	flag.BoolVar(&showVersion, "version", false, "Print version information and exit")
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "dlfast: High-performance download tool powered by aria2c\n\n")
-		fmt.Fprintf(os.Stderr, "Usage: dlfast [options] <URL> [URL2 ...]\n\n")
+		fmt.Fprintf(os.Stderr, "Usage: dlfast [options] <URL> [URL2 ...]\n")
+		fmt.Fprintf(os.Stderr, "       dlfast [options] -i <file>\n\n")
 		fmt.Fprintf(os.Stderr, "Examples:\n")
 		fmt.Fprintf(os.Stderr, "  dlfast https://example.com/file.zip\n")
 		fmt.Fprintf(os.Stderr, "  dlfast -d ~/Downloads https://example.com/file1.zip https://example.com/file2.tar.gz\n")
 		fmt.Fprintf(os.Stderr, "  dlfast --max-speed 1M --parallel 2 url1 url2 url3\n")
-		fmt.Fprintf(os.Stderr, "  dlfast --user-agent \"MyBot/1.0\" --timeout 120 https://example.com/large.iso\n\n")
+		fmt.Fprintf(os.Stderr, "  dlfast --user-agent \"MyBot/1.0\" --timeout 120 https://example.com/large.iso\n")
+		fmt.Fprintf(os.Stderr, "  printf 'https://example.com/a.iso\\t~/ISOs\\n' | dlfast -i -\n\n")
 		fmt.Fprintf(os.Stderr, "Features:\n")
 		fmt.Fprintf(os.Stderr, "  • Intelligent filename detection via HTTP Content-Disposition headers\n")
 		fmt.Fprintf(os.Stderr, "  • Parallel batch downloads with configurable concurrency\n")
 		fmt.Fprintf(os.Stderr, "  • Optimized for high-speed downloads (16 connections, 32 splits)\n")
 		fmt.Fprintf(os.Stderr, "  • Robust signal handling and error recovery\n")
-		fmt.Fprintf(os.Stderr, "  • Resume support for interrupted downloads\n\n")
+		fmt.Fprintf(os.Stderr, "  • Resume support for interrupted downloads\n")
+		fmt.Fprintf(os.Stderr, "  • Optional JSON metadata sidecars for archival\n\n")
 		fmt.Fprintf(os.Stderr, "Options:\n")
 		flag.PrintDefaults()
 	}
 
+	// --completion is deliberately not a registered flag so it stays out of --help.
+	if len(os.Args) == 3 && (os.Args[1] == "--completion" || os.Args[1] == "-completion") {
+		if err := writeCompletion(os.Stdout, os.Args[2]); err != nil {
+			term.Fatalf("%v", err)
+		}
+		return
+	}
+
 	flag.Parse()
 
-	if flag.NArg() == 0 {
+	// With --progress-json a usage error is also reported as an error event,
+	// so consumers reading stdout see why the run never started.
+	fatalf := func(format string, args ...interface{}) {
+		if config.ProgressJSON {
+			emitEvent(progressEvent{Event: "error", Error: fmt.Sprintf(format, args...)})
+		}
+		term.Fatalf(format, args...)
+	}
+
+	if showVersion {
+		fmt.Println(version.String("dlfast"))
+		return
+	}
+
+	if err := term.SetColorMode(colorMode); err != nil {
+		fatalf("--color: %v", err)
+	}
+
+	var jobs []downloadJob
+	for _, rawURL := range flag.Args() {
+		jobs = append(jobs, downloadJob{URL: rawURL})
+	}
+	if inputFile != "" {
+		fileJobs, err := readJobFile(inputFile)
+		if err != nil {
+			fatalf("reading URLs from '%s': %v", inputFile, err)
+		}
+		jobs = append(jobs, fileJobs...)
+	}
+
+	if len(jobs) == 0 {
 		flag.Usage()
 		os.Exit(1)
 	}
 
-	// Check for aria2c availability
-	if _, err := exec.LookPath("aria2c"); err != nil {
-		fmt.Fprintf(os.Stderr, "%sError: aria2c not found in PATH. Please install aria2c.%s\n", colorRed, colorReset)
-		os.Exit(1)
+	streaming := config.OutputFile == "-"
+	if config.OutputFile != "" && !streaming {
+		fatalf("-O only supports '-' (stdout); use -d to choose a directory")
+	}
+	if streaming && len(jobs) > 1 {
+		fatalf("-O - streams exactly one URL, got %d", len(jobs))
 	}
 
-	urls := flag.Args()
+	if config.HTTPPasswd != "" && config.HTTPUser == "" {
+		fatalf("--http-passwd requires --http-user")
+	}
+	if config.BearerToken != "" && config.HTTPUser != "" {
+		fatalf("--bearer and --http-user cannot be used together; both set the Authorization header")
+	}
+
+	if config.CutDirs < 0 {
+		fatalf("--cut-dirs cannot be negative")
+	}
+	if config.CutDirs > 0 && !config.MirrorPath {
+		fatalf("--cut-dirs only makes sense with --mirror-path")
+	}
+	if config.MirrorPath && streaming {
+		fatalf("--mirror-path cannot be used with -O -, which writes to stdout")
+	}
+
+	if config.ProgressJSON {
+		if streaming || speedtest || config.Interactive {
+			fatalf("--progress-json cannot be combined with -O -, --speedtest or --interactive")
+		}
+		// Progress comes from polling the aria2c daemon; human output is turned off.
+		config.UseRPC = true
+		config.Quiet = true
+	}
+
+	if config.SummaryFile != "" && (streaming || speedtest) {
+		fatalf("--summary-file cannot be used with -O - or --speedtest")
+	}
+
+	if browserUA {
+		if config.UserAgent != "" {
+			fatalf("--browser-ua and --user-agent cannot be used together")
+		}
+		config.UserAgent = browserUserAgent
+	}
+
+	if config.MaxRedirects < 0 {
+		fatalf("--max-redirects cannot be negative")
+	}
+
+	if config.IPVersion != 0 && config.IPVersion != 4 && config.IPVersion != 6 {
+		fatalf("--ip-version must be 4 or 6, got %d", config.IPVersion)
+	}
+
+	if config.NoClobber && config.Interactive {
+		fatalf("--no-clobber and --interactive cannot be used together")
+	}
+
+	// Check for aria2c availability (the speed test and -O - don't use it)
+	if _, err := exec.LookPath("aria2c"); err != nil && !speedtest && !streaming {
+		if !config.AllowFallback {
+			fatalf("aria2c not found in PATH. Please install aria2c or pass --allow-fallback.")
+		}
+		if config.ProgressJSON {
+			fatalf("--progress-json needs aria2c, which was not found in PATH")
+		}
+		fmt.Fprintf(os.Stderr, "%sWarning: aria2c not found, falling back to single-connection HTTP downloads.%s\n", term.Yellow, term.Reset)
+		config.UseHTTP = true
+	}
+
+	if config.Referer != "" {
+		if err := validateURL(config.Referer); err != nil {
+			fatalf("--referer: %v", err)
+		}
+	}
+
+	if config.PerHostLimit < 0 {
+		fatalf("--per-host cannot be negative")
+	}
+	if config.PerHostLimit > 0 && config.UseRPC {
+		// The daemon gets the whole batch at once and has no per-host limit of its own.
+		fatalf("--per-host is not supported with --rpc or --progress-json")
+	}
+
+	if autoParallel {
+		parallelSet := false
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "parallel" {
+				parallelSet = true
+			}
+		})
+		if parallelSet {
+			fatalf("--auto-parallel and --parallel cannot be used together")
+		}
+		if config.PerHostLimit == 0 {
+			config.PerHostLimit = autoPerHostDownloads
+		}
+		config.ParallelDownloads = autoParallelism(jobs, config.PerHostLimit)
+		if !config.Quiet && len(jobs) > 1 {
+			fmt.Printf("Auto-parallel: %s%d%s downloads at a time\n", term.Cyan, config.ParallelDownloads, term.Reset)
+		}
+	}
+
+	if config.MaxTotalSpeed != "" {
+		if _, err := parseSpeed(config.MaxTotalSpeed); err != nil {
+			fatalf("--max-total-speed: %v", err)
+		}
+	}
+
+	if config.CookieFile != "" {
+		jar, err := loadCookieJar(config.CookieFile)
+		if err != nil {
+			fatalf("--load-cookies %s: %v", config.CookieFile, err)
+		}
+		config.CookieJar = jar
+	}
 
 	// Setup signal handling
 	ctx, cancel := context.WithCancel(context.Background())
@@ -515,25 +2496,69 @@ func main() {
 
 	go func() {
 		<-sigChan
-		fmt.Fprintf(os.Stderr, "\n%sReceived interrupt signal, cancelling downloads...%s\n", colorYellow, colorReset)
+		fmt.Fprintf(os.Stderr, "\n%sReceived interrupt signal, cancelling downloads...%s\n", term.Yellow, term.Reset)
 		cancel()
 	}()
 
+	if streaming {
+		if err := streamToStdout(ctx, jobs[0].URL, config); err != nil {
+			if errors.Is(err, context.Canceled) {
+				os.Exit(130)
+			}
+			fatalf("%v", err)
+		}
+		return
+	}
+
+	if speedtest {
+		failed := false
+		for _, job := range jobs {
+			if err := runSpeedtest(ctx, job.URL, config); err != nil {
+				if errors.Is(err, context.Canceled) {
+					os.Exit(130)
+				}
+				fmt.Fprintf(os.Stderr, "%sSpeed test failed for %s: %v%s\n", term.Red, job.URL, err, term.Reset)
+				failed = true
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Run downloads
-	if err := runDownloads(ctx, urls, config); err != nil {
+	err := runDownloads(ctx, jobs, config)
+
+	summary := runSummary{Status: "ok", URLs: len(jobs), Finished: time.Now()}
+	switch {
+	case errors.Is(err, context.Canceled):
+		summary.Status, summary.Message = "cancelled", "Downloads cancelled."
+	case err != nil:
+		summary.Status, summary.Message = "failed", err.Error()
+	case len(jobs) == 1:
+		summary.Message = "Download completed successfully!"
+	default:
+		summary.Message = "All downloads completed successfully!"
+	}
+	if config.SummaryFile != "" {
+		if werr := writeSummary(config.SummaryFile, summary, config.ProgressJSON); werr != nil {
+			fmt.Fprintf(os.Stderr, "%sWarning: could not write summary file: %v%s\n", term.Yellow, werr, term.Reset)
+		}
+	}
+
+	if err != nil {
+		if config.ProgressJSON {
+			emitEvent(progressEvent{Event: "error", Error: err.Error()})
+		}
 		if errors.Is(err, context.Canceled) {
-			fmt.Fprintf(os.Stderr, "%sDownloads cancelled.%s\n", colorYellow, colorReset)
+			fmt.Fprintf(os.Stderr, "%s%s%s\n", term.Yellow, summary.Message, term.Reset)
 			os.Exit(130)
 		}
-		fmt.Fprintf(os.Stderr, "%sError: %v%s\n", colorRed, err, colorReset)
-		os.Exit(1)
+		term.Fatalf("%v", err)
 	}
 
 	if !config.Quiet {
-		if len(urls) == 1 {
-			fmt.Printf("%sDownload completed successfully!%s\n", colorGreen, colorReset)
-		} else {
-			fmt.Printf("%sAll downloads completed successfully!%s\n", colorGreen, colorReset)
-		}
+		fmt.Printf("%s%s%s\n", term.Green, summary.Message, term.Reset)
 	}
-}
\ No newline at end of file
+}
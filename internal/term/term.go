@@ -0,0 +1,61 @@
+// Package term holds the terminal helpers shared by the GoferShell tools:
+// ANSI color codes and fatal error reporting.
+package term
+
+import (
+	"fmt"
+	"os"
+)
+
+// ANSI color codes. They are emptied by SetColorMode when color is off.
+var (
+	Red    = "\033[31m"
+	Green  = "\033[32m"
+	Yellow = "\033[33m"
+	Cyan   = "\033[36m"
+	Bold   = "\033[1m"
+	Reset  = "\033[0m"
+)
+
+// ClearScreen moves the cursor home and clears the terminal.
+const ClearScreen = "\033[H\033[2J"
+
+// Values accepted by SetColorMode and the tools' --color flag.
+const (
+	ColorAuto   = "auto"
+	ColorAlways = "always"
+	ColorNever  = "never"
+)
+
+// SetColorMode enables or disables colored output. In auto mode color is
+// disabled when NO_COLOR is set or stdout is not a terminal.
+func SetColorMode(mode string) error {
+	switch mode {
+	case ColorAlways:
+	case ColorNever:
+		disableColor()
+	case ColorAuto:
+		if os.Getenv("NO_COLOR") != "" || !IsTerminal(os.Stdout) {
+			disableColor()
+		}
+	default:
+		return fmt.Errorf("invalid color mode %q (want auto, always or never)", mode)
+	}
+	return nil
+}
+
+// IsTerminal reports whether f is a character device such as a TTY.
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func disableColor() {
+	Red, Green, Yellow, Cyan, Bold, Reset = "", "", "", "", "", ""
+}
+
+// Fatalf prints a formatted error message to stderr and exits with status 1.
+func Fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "%sError: %s%s\n", Red, fmt.Sprintf(format, args...), Reset)
+	os.Exit(1)
+}
@@ -0,0 +1,20 @@
+// Package version carries build metadata injected at link time, e.g.:
+//
+//	go build -ldflags "-X github.com/Evren-os/GoferShell/internal/version.Version=v1.2.0 \
+//	  -X github.com/Evren-os/GoferShell/internal/version.Commit=$(git rev-parse --short HEAD) \
+//	  -X github.com/Evren-os/GoferShell/internal/version.Date=$(date -u +%Y-%m-%d)" dlfast.go
+package version
+
+import "fmt"
+
+// Set with -ldflags -X; the defaults mark a plain development build.
+var (
+	Version = "dev"
+	Commit  = "unknown"
+	Date    = "unknown"
+)
+
+// String formats the build metadata for the named tool.
+func String(tool string) string {
+	return fmt.Sprintf("%s %s (commit %s, built %s)", tool, Version, Commit, Date)
+}
@@ -0,0 +1,34 @@
+// Package ytdlp holds yt-dlp settings shared by the GoferShell video tools.
+package ytdlp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Codec preferences accepted by -codec.
+const (
+	CodecAV1  = "av1"
+	CodecVP9  = "vp9"
+	CodecHEVC = "hevc"
+)
+
+// Codecs lists the supported codec preferences in the order shown to users.
+var Codecs = []string{CodecAV1, CodecVP9, CodecHEVC}
+
+// codecSortStrings maps a codec preference to a yt-dlp --format-sort value.
+// Resolution and frame rate always win; the codec only breaks ties.
+var codecSortStrings = map[string]string{
+	CodecAV1:  "res,fps,vcodec:av01,vcodec:vp9.2,vcodec:vp9,vcodec:hev1,acodec:opus",
+	CodecVP9:  "res,fps,vcodec:vp9,vcodec:vp9.2,vcodec:av01,vcodec:hev1,acodec:opus",
+	CodecHEVC: "res,fps,vcodec:hev1,vcodec:h265,vcodec:av01,vcodec:vp9.2,vcodec:vp9,acodec:opus",
+}
+
+// CodecSortString returns the --format-sort value for a codec preference.
+func CodecSortString(codec string) (string, error) {
+	sort, ok := codecSortStrings[strings.ToLower(codec)]
+	if !ok {
+		return "", fmt.Errorf("invalid codec preference %q. Use one of: %s", codec, strings.Join(Codecs, ", "))
+	}
+	return sort, nil
+}
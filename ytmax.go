@@ -1,96 +1,441 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"net/url"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
-)
+	"time"
 
-const (
-	colorRed    = "\033[31m"
-	colorGreen  = "\033[32m"
-	colorYellow = "\033[33m"
-	colorCyan   = "\033[36m"
-	colorReset  = "\033[0m"
+	"github.com/Evren-os/GoferShell/internal/term"
+	"github.com/Evren-os/GoferShell/internal/version"
+	"github.com/Evren-os/GoferShell/internal/ytdlp"
 )
 
 // Constants for yt-dlp arguments and settings.
 const (
 	defaultFilenamePattern = "%(title)s [%(id)s][%(height)sp][%(fps)sfps][%(vcodec)s][%(acodec)s].%(ext)s"
 	defaultMergeFormat     = "mkv"
-	codecAV1               = "av1"
-	codecVP9               = "vp9"
+	defaultChapterPattern  = "%(title)s - %(section_number)03d %(section_title)s [%(id)s].%(ext)s"
+	defaultMaxHeight       = 2160
+	defaultRetries         = 2
+	defaultConnections     = 16 // aria2c's per-server maximum
+	defaultSplits          = 32
+	killGracePeriod        = 10 * time.Second
+	retryDelay             = 5 * time.Second
 
 	// Settings for social media compatibility (optimized for modern platforms).
 	socmFormat      = "bv*[vcodec^=avc][height<=1080]+ba[acodec^=mp4a]/b[vcodec^=avc][height<=1080]"
 	socmMergeFormat = "mp4"
+
+	// Prefix that numbers playlist entries ("003 - ") and expands to nothing for single videos.
+	playlistIndexPrefix = "%(playlist_index&{} - |)s"
+
+	// Settings for audio-only downloads.
+	audioFormatSelector = "bestaudio/best"
+	defaultAudioFormat  = "opus"
 )
 
-// fatalf prints a formatted error message to stderr and exits with status 1.
-func fatalf(format string, args ...interface{}) {
-	errorMessage := fmt.Sprintf(format, args...)
-	fmt.Fprintf(os.Stderr, "%sError: %s%s\n", colorRed, errorMessage, colorReset)
-	os.Exit(1)
+// mediaExtensions lists the file extensions that make a missing -d path a
+// file path rather than a directory.
+var mediaExtensions = []string{".3gp", ".aac", ".avi", ".flac", ".flv", ".m4a", ".m4v", ".mkv", ".mov", ".mp3", ".mp4", ".oga", ".ogg", ".opus", ".ts", ".wav", ".webm"}
+
+// audioFormats lists the values yt-dlp accepts for --audio-format.
+var audioFormats = []string{"best", "aac", "alac", "flac", "m4a", "mp3", "opus", "vorbis", "wav"}
+
+// sponsorBlockCategories lists the categories yt-dlp can remove via --sponsorblock-remove.
+var sponsorBlockCategories = []string{
+	"all", "default", "sponsor", "intro", "outro", "selfpromo", "preview",
+	"filler", "interaction", "music_offtopic", "chapter",
+}
+
+// Browsers and Linux keyrings yt-dlp can read cookies from.
+var (
+	cookieBrowsers = []string{"brave", "chrome", "chromium", "edge", "firefox", "opera", "safari", "vivaldi", "whale"}
+	cookieKeyrings = []string{"basictext", "gnomekeyring", "kwallet", "kwallet5", "kwallet6"}
+)
+
+// Config holds the user's download preferences shared by single and batch mode.
+type Config struct {
+	CodecPref        string
+	DestinationPath  string
+	DestIsDir        bool // DestinationPath names a directory, not a file template
+	CookiesFrom      string
+	CookiesKeyring   string
+	Socm             bool
+	Audio            bool
+	AudioFormat      string
+	SubLangs         string
+	AutoSubs         bool
+	EmbedSubs        bool
+	SponsorBlock     bool
+	SponsorBlockCats string
+	PlaylistItems    string
+	NoPlaylist       bool
+	EmbedThumbnail   bool
+	EmbedMetadata    bool
+	SplitChapters    bool
+	WriteInfoJSON    bool
+	WriteDescription bool
+	KeepMtime        bool // let yt-dlp set the file time to the upload time
+	ArchiveFile      string
+	MaxHeight        int
+	Format           string // custom yt-dlp format selector, replaces the -max-res one
+	OutputTemplate   string
+	Retries          int
+	RetryFailed      int
+	SummaryProgress  bool
+	ReportFile       string
+	Timeout          time.Duration
+	TotalTimeout     time.Duration
+	LiveFromStart    bool
+	ExtraArgs        []string // passed verbatim to yt-dlp, from after "--"
+	LimitRate        string
+	NoAria2c         bool
+	Connections      int
+	Splits           int
+}
+
+// rateLimitRe matches aria2c speed values such as 500K or 2M.
+var rateLimitRe = regexp.MustCompile(`^[0-9]+[KkMm]?$`)
+
+// progressWriter passes yt-dlp output through while noting whether any
+// download progress was reported, which marks a failure as transient.
+type progressWriter struct {
+	out         io.Writer
+	sawProgress *atomic.Bool
+}
+
+func (w progressWriter) Write(p []byte) (int, error) {
+	if bytes.Contains(p, []byte("[download]")) && bytes.Contains(p, []byte("%")) {
+		w.sawProgress.Store(true)
+	}
+	return w.out.Write(p)
 }
 
+// playlistItemsRe matches yt-dlp --playlist-items specs such as "1-5,8" or "1:10:2".
+var playlistItemsRe = regexp.MustCompile(`^-?\d*(?:[-:]-?\d*){0,2}(?:,-?\d*(?:[-:]-?\d*){0,2})*$`)
+
 // checkDependencies ensures that all required command-line tools are installed and in the PATH.
 func checkDependencies(cmds ...string) {
 	for _, cmd := range cmds {
 		if _, err := exec.LookPath(cmd); err != nil {
-			fatalf("%s is not installed or not found in PATH", cmd)
+			term.Fatalf("%s is not installed or not found in PATH", cmd)
+		}
+	}
+}
+
+// splitPassthrough separates ytmax's own arguments from the yt-dlp arguments
+// that follow a "--" separator. The split has to happen before flag parsing,
+// since the flag package stops at the first URL and would treat "--" as a URL.
+func splitPassthrough(args []string) (own, passthrough []string) {
+	for i, arg := range args {
+		if arg == "--" {
+			return args[:i], args[i+1:]
+		}
+	}
+	return args, nil
+}
+
+// buildCookiesFromBrowser validates a -cookies-from value and folds in the
+// keyring, producing yt-dlp's BROWSER[+KEYRING][:PROFILE][::CONTAINER] syntax.
+func buildCookiesFromBrowser(value, keyring string) (string, error) {
+	spec, container, hasContainer := strings.Cut(value, "::")
+	spec, profile, hasProfile := strings.Cut(spec, ":")
+	browser, specKeyring, hasKeyring := strings.Cut(spec, "+")
+
+	browser = strings.ToLower(browser)
+	if !slices.Contains(cookieBrowsers, browser) {
+		return "", fmt.Errorf("unsupported browser '%s' for -cookies-from. Use one of: %s", browser, strings.Join(cookieBrowsers, ", "))
+	}
+
+	if hasKeyring && keyring != "" && !strings.EqualFold(specKeyring, keyring) {
+		return "", fmt.Errorf("keyring given both in -cookies-from (%s) and -cookies-keyring (%s)", specKeyring, keyring)
+	}
+	if keyring == "" {
+		keyring = specKeyring
+	}
+	if keyring != "" {
+		keyring = strings.ToLower(keyring)
+		if !slices.Contains(cookieKeyrings, keyring) {
+			return "", fmt.Errorf("unsupported keyring '%s'. Use one of: %s", keyring, strings.Join(cookieKeyrings, ", "))
+		}
+		if browser == "firefox" || browser == "safari" {
+			return "", fmt.Errorf("keyrings only apply to Chromium-based browsers, not %s", browser)
+		}
+	}
+
+	if hasProfile && profile == "" {
+		return "", fmt.Errorf("empty profile in -cookies-from '%s'", value)
+	}
+	if hasContainer && container == "" {
+		return "", fmt.Errorf("empty container in -cookies-from '%s'", value)
+	}
+
+	result := browser
+	if keyring != "" {
+		result += "+" + keyring
+	}
+	if hasProfile {
+		result += ":" + profile
+	}
+	if hasContainer {
+		result += "::" + container
+	}
+	return result, nil
+}
+
+// normalizeSubLangs cleans a comma-separated language list such as "en, es".
+func normalizeSubLangs(langs string) (string, error) {
+	var cleaned []string
+	for _, lang := range strings.Split(langs, ",") {
+		lang = strings.TrimSpace(lang)
+		if lang == "" {
+			return "", fmt.Errorf("empty language in subtitle list '%s'", langs)
+		}
+		cleaned = append(cleaned, lang)
+	}
+	return strings.Join(cleaned, ","), nil
+}
+
+// normalizeSponsorBlockCats validates a comma-separated SponsorBlock category list.
+func normalizeSponsorBlockCats(cats string) (string, error) {
+	var cleaned []string
+	for _, cat := range strings.Split(cats, ",") {
+		cat = strings.ToLower(strings.TrimSpace(cat))
+		if !slices.Contains(sponsorBlockCategories, cat) {
+			return "", fmt.Errorf("unknown SponsorBlock category '%s'. Use any of: %s", cat, strings.Join(sponsorBlockCategories, ", "))
+		}
+		cleaned = append(cleaned, cat)
+	}
+	return strings.Join(cleaned, ","), nil
+}
+
+// validateURL catches obviously broken URLs before yt-dlp is started for them.
+// Any site yt-dlp supports is accepted; YouTube links also get a shape check
+// so a mistyped video link fails up front.
+func validateURL(rawURL string) error {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New("not an http(s) URL")
+	}
+	// Any non-empty host is accepted (IP literals and single-label intranet
+	// names included); whether it resolves is left to yt-dlp.
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return errors.New("missing host")
+	}
+
+	switch strings.TrimPrefix(host, "www.") {
+	case "youtu.be":
+		if strings.Trim(u.Path, "/") == "" {
+			return errors.New("youtu.be link has no video ID")
+		}
+	case "youtube.com", "m.youtube.com", "music.youtube.com":
+		if u.Path == "/watch" && u.Query().Get("v") == "" {
+			return errors.New("YouTube watch link has no v= video ID")
 		}
 	}
+	return nil
 }
 
-// validateURL performs basic URL validation.
-func validateURL(rawURL string) bool {
-	rawURL = strings.TrimSpace(rawURL)
-	if rawURL == "" {
-		return false
+// readURLList reads URLs from a file, or stdin when path is "-". URLs may be
+// one per line or comma-separated; blank lines and # comments are ignored.
+func readURLList(path string) ([]string, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
 	}
-	_, err := url.Parse(rawURL)
-	return err == nil && (strings.HasPrefix(rawURL, "http://") || strings.HasPrefix(rawURL, "https://"))
+
+	var urls []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, rawURL := range strings.Split(line, ",") {
+			if rawURL = strings.TrimSpace(rawURL); rawURL != "" {
+				urls = append(urls, rawURL)
+			}
+		}
+	}
+	return urls, nil
 }
 
-// sanitizeAndDeduplicateURLs cleans and deduplicates the URL list.
-func sanitizeAndDeduplicateURLs(urls []string) []string {
+// youtubeTrackingParams are query parameters YouTube adds to shared links
+// that don't change which video is played.
+var youtubeTrackingParams = []string{"si", "feature", "pp", "ab_channel"}
+
+// normalizeURL rewrites YouTube links to one canonical form so share-link
+// variants of the same video compare equal. Other URLs are returned as-is.
+func normalizeURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	query := u.Query()
+	switch host {
+	case "youtu.be":
+		id := strings.Trim(u.Path, "/")
+		if id == "" {
+			return rawURL
+		}
+		query.Set("v", id)
+		u.Path = "/watch"
+	case "youtube.com", "m.youtube.com":
+	default:
+		return rawURL
+	}
+
+	for _, param := range youtubeTrackingParams {
+		query.Del(param)
+	}
+	u.Scheme = "https"
+	u.Host = "www.youtube.com"
+	u.RawQuery = query.Encode()
+	u.Fragment = ""
+	return u.String()
+}
+
+// sanitizeAndDeduplicateURLs cleans, normalizes and deduplicates the URL list,
+// keeping the first occurrence of each. Invalid entries are returned separately
+// as failed results so they can be reported without starting yt-dlp.
+func sanitizeAndDeduplicateURLs(urls []string) (result []string, rejected []downloadResult) {
 	seen := make(map[string]bool)
-	var result []string
+	duplicates := 0
 
 	for _, rawURL := range urls {
 		cleanURL := strings.TrimSpace(rawURL)
 		if cleanURL == "" {
 			continue
 		}
-		if !validateURL(cleanURL) {
-			fmt.Printf("%sWarning: Skipping invalid URL: %s%s\n", colorYellow, cleanURL, colorReset)
+		if err := validateURL(cleanURL); err != nil {
+			fmt.Printf("%sWarning: Skipping invalid URL: %s (%v)%s\n", term.Yellow, cleanURL, err, term.Reset)
+			if !seen[cleanURL] {
+				seen[cleanURL] = true
+				rejected = append(rejected, downloadResult{URL: cleanURL, Error: "invalid URL: " + err.Error()})
+			}
 			continue
 		}
-		if !seen[cleanURL] {
-			seen[cleanURL] = true
-			result = append(result, cleanURL)
+		cleanURL = normalizeURL(cleanURL)
+		if seen[cleanURL] {
+			duplicates++
+			continue
+		}
+		seen[cleanURL] = true
+		result = append(result, cleanURL)
+	}
+
+	if duplicates > 0 {
+		fmt.Printf("Note: dropped %s%d%s duplicate URL(s)\n", term.Cyan, duplicates, term.Reset)
+	}
+	return result, rejected
+}
+
+// buildAria2cDownloaderArgs builds the argument string yt-dlp hands to aria2c.
+// The rate limit is applied here because aria2c does the actual transfer.
+func buildAria2cDownloaderArgs(config *Config) string {
+	args := fmt.Sprintf("-x %d -s %d -k 1M --disk-cache=128M --enable-color=false", config.Connections, config.Splits)
+	if config.LimitRate != "" {
+		args += " --max-download-limit=" + config.LimitRate
+	}
+	return args
+}
+
+// resolveDestination decides whether the -d value names a directory or a
+// file path. A trailing separator, an existing directory or a missing path
+// without an extension is a directory and is created if needed. A missing
+// path with a media extension is a file path whose parent directory must
+// already exist; any other extension is ambiguous and rejected. yt-dlp
+// templates are never created here, as yt-dlp expands and creates them.
+func resolveDestination(dest string) (path string, isDir bool, err error) {
+	trailingSep := strings.HasSuffix(dest, "/") || strings.HasSuffix(dest, string(filepath.Separator))
+	path = filepath.Clean(dest)
+
+	info, err := os.Stat(path)
+	switch {
+	case err == nil && info.IsDir():
+		return path, true, nil
+	case err == nil:
+		if trailingSep {
+			return "", false, fmt.Errorf("destination '%s' ends with a separator but is an existing file", dest)
+		}
+		return path, false, nil
+	case !errors.Is(err, os.ErrNotExist):
+		return "", false, fmt.Errorf("checking destination '%s': %w", dest, err)
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+	switch {
+	case strings.Contains(path, "%("):
+		// A templated directory such as %(uploader)s is created by yt-dlp itself.
+		if trailingSep {
+			return path, true, nil
+		}
+	case trailingSep || ext == "":
+		if err := os.MkdirAll(path, 0o755); err != nil {
+			return "", false, fmt.Errorf("creating destination directory: %w", err)
 		}
+		return path, true, nil
+	case !slices.Contains(mediaExtensions, ext):
+		return "", false, fmt.Errorf("destination '%s' does not exist and '%s' is not a media extension; end -d with '/' if it is a directory", dest, ext)
 	}
 
-	return result
+	parent := filepath.Dir(path)
+	if strings.Contains(parent, "%(") {
+		return path, false, nil
+	}
+	if info, err := os.Stat(parent); err != nil || !info.IsDir() {
+		return "", false, fmt.Errorf("destination '%s' looks like a file path but its directory '%s' does not exist; end -d with '/' to create it as a directory", dest, parent)
+	}
+	return path, false, nil
 }
 
 // buildYTDLPArgs constructs the command-line arguments for yt-dlp based on user flags.
-func buildYTDLPArgs(url, codecPref, destinationPath, cookiesFrom string, socm bool) []string {
-	// Determine output template.
-	outputTemplate := defaultFilenamePattern
-	if destinationPath != "" {
-		if info, err := os.Stat(destinationPath); err == nil && info.IsDir() {
-			outputTemplate = filepath.Join(destinationPath, defaultFilenamePattern)
+func buildYTDLPArgs(url string, config *Config) []string {
+	// Determine output template. Picking playlist items numbers the default
+	// filenames so they sort in playlist order; a custom template or a full
+	// file path given via -d is used verbatim.
+	filenamePattern := config.OutputTemplate
+	if config.PlaylistItems != "" && filenamePattern == defaultFilenamePattern {
+		filenamePattern = playlistIndexPrefix + defaultFilenamePattern
+	}
+
+	outputTemplate := filenamePattern
+	chapterTemplate := defaultChapterPattern
+	if config.DestinationPath != "" {
+		if config.DestIsDir {
+			outputTemplate = filepath.Join(config.DestinationPath, filenamePattern)
+			chapterTemplate = filepath.Join(config.DestinationPath, defaultChapterPattern)
 		} else {
-			outputTemplate = destinationPath
+			outputTemplate = config.DestinationPath
+			chapterTemplate = filepath.Join(filepath.Dir(config.DestinationPath), defaultChapterPattern)
 		}
 	}
 
@@ -98,35 +443,69 @@ func buildYTDLPArgs(url, codecPref, destinationPath, cookiesFrom string, socm bo
 	args := []string{
 		"--prefer-free-formats",
 		"--format-sort-force",
-		"--no-mtime",
 		"--output", outputTemplate,
-		"--external-downloader", "aria2c",
-		"--external-downloader-args", "-x 16 -s 32 -k 1M --disk-cache=128M --enable-color=false",
+	}
+	if !config.KeepMtime {
+		args = append(args, "--no-mtime")
+	}
+
+	if config.NoAria2c {
+		// yt-dlp's built-in downloader enforces the rate limit itself.
+		if config.LimitRate != "" {
+			args = append(args, "--limit-rate", config.LimitRate)
+		}
+	} else {
+		args = append(args,
+			"--external-downloader", "aria2c",
+			"--external-downloader-args", buildAria2cDownloaderArgs(config),
+		)
+	}
+
+	if config.CookiesFrom != "" {
+		args = append(args, "--cookies-from-browser", config.CookiesFrom)
 	}
 
-	if cookiesFrom != "" {
-		args = append(args, "--cookies-from-browser", cookiesFrom)
+	if config.ArchiveFile != "" {
+		// Already-archived videos are skipped and yt-dlp still exits 0,
+		// so a fully archived playlist counts as a successful no-op.
+		args = append(args, "--download-archive", config.ArchiveFile)
 	}
 
-	if socm {
+	if config.LiveFromStart {
+		// Live fragments use yt-dlp's own downloader; aria2c only handles finished videos.
+		args = append(args, "--live-from-start")
+	}
+
+	if config.NoPlaylist {
+		args = append(args, "--no-playlist")
+	} else if config.PlaylistItems != "" {
+		args = append(args, "--playlist-items", config.PlaylistItems)
+	}
+
+	if config.Audio {
+		// Audio-only: no merging or video sorting, just extract the best audio track.
+		args = append(args,
+			"--format", audioFormatSelector,
+			"--extract-audio",
+			"--audio-format", config.AudioFormat,
+		)
+	} else if config.Socm {
 		// Social media compatibility settings override others.
 		args = append(args,
 			"--merge-output-format", socmMergeFormat,
 			"--format", socmFormat,
 		)
 	} else {
-		// Standard high-quality download settings.
-		maxHeight := 2160
-		formatString := fmt.Sprintf("bv*[height<=%d]+ba/bv*[height<=%d]", maxHeight, maxHeight)
+		// Standard high-quality download settings. The trailing /b catches sources
+		// with no separate video stream, such as audio-only or single-file uploads.
+		formatString := fmt.Sprintf("bv*[height<=%d]+ba/bv*[height<=%d]/b", config.MaxHeight, config.MaxHeight)
+		if config.Format != "" {
+			formatString = config.Format
+		}
 
-		var sortString string
-		switch strings.ToLower(codecPref) {
-		case codecAV1:
-			sortString = "res,fps,vcodec:av01,vcodec:vp9.2,vcodec:vp9,vcodec:hev1,acodec:opus"
-		case codecVP9:
-			sortString = "res,fps,vcodec:vp9,vcodec:vp9.2,vcodec:av01,vcodec:hev1,acodec:opus"
-		default:
-			fatalf("Invalid codec preference. Use '%s' or '%s'.", codecAV1, codecVP9)
+		sortString, err := ytdlp.CodecSortString(config.CodecPref)
+		if err != nil {
+			term.Fatalf("%v", err)
 		}
 
 		args = append(args,
@@ -136,59 +515,417 @@ func buildYTDLPArgs(url, codecPref, destinationPath, cookiesFrom string, socm bo
 		)
 	}
 
+	// Subtitles. Embedding works with both the mkv and mp4 merge formats.
+	if config.SubLangs != "" {
+		args = append(args, "--write-subs", "--sub-langs", config.SubLangs)
+	}
+	if config.AutoSubs {
+		// Auto-generated captions are only used when no manual track exists.
+		args = append(args, "--write-auto-subs")
+	}
+	if config.EmbedSubs {
+		args = append(args, "--embed-subs")
+	}
+
+	if config.SponsorBlock {
+		args = append(args, "--sponsorblock-remove", config.SponsorBlockCats)
+	}
+
+	if config.EmbedThumbnail {
+		// YouTube serves webp thumbnails, which mp4 can't carry as cover art.
+		// jpg embeds cleanly in mkv, mp4 and the audio containers alike.
+		args = append(args, "--embed-thumbnail", "--convert-thumbnails", "jpg")
+	}
+	if config.EmbedMetadata {
+		// --embed-metadata is the current name of --add-metadata.
+		args = append(args, "--embed-metadata")
+	}
+
+	if config.SplitChapters {
+		// Chapter files have their own template, which yt-dlp would otherwise
+		// resolve against the working directory rather than -d. A video without
+		// chapters is simply kept whole and yt-dlp still exits 0.
+		args = append(args, "--split-chapters", "--output", "chapter:"+chapterTemplate)
+	}
+
+	// Sidecars take the video's output template, so they land next to it
+	// whether -d is a directory or a full file path.
+	if config.WriteInfoJSON {
+		args = append(args, "--write-info-json")
+	}
+	if config.WriteDescription {
+		args = append(args, "--write-description")
+	}
+
+	// User-supplied yt-dlp options go last so they can override anything above.
+	args = append(args, config.ExtraArgs...)
+
 	// Finally, add the URL.
 	args = append(args, url)
 	return args
 }
 
-// downloadURL executes yt-dlp for a single URL in a goroutine.
-func downloadURL(url, codecPref, destinationPath, cookiesFrom string, socm bool, wg *sync.WaitGroup, sem chan struct{}, failedURLsChan chan<- string) {
-	defer wg.Done()
-	defer func() { <-sem }() // Release semaphore slot.
+// errLiveStream marks a failure caused by the URL being a live or upcoming stream.
+var errLiveStream = errors.New("target is a live or upcoming stream")
+
+// liveStreamMarkers are fragments of yt-dlp errors about live content.
+var liveStreamMarkers = []string{
+	"live event will begin",
+	"premieres in",
+	"live stream recording is not available",
+	"this live stream",
+	"--live-from-start",
+}
+
+// looksLikeLiveStream reports whether yt-dlp's stderr points at live content.
+func looksLikeLiveStream(output string) bool {
+	output = strings.ToLower(output)
+	for _, marker := range liveStreamMarkers {
+		if strings.Contains(output, marker) {
+			return true
+		}
+	}
+	return false
+}
 
-	fmt.Printf("Starting download: %s%s%s\n", colorCyan, url, colorReset)
+// listURLFormats prints yt-dlp's table of available formats for a URL
+// instead of downloading it. Cookies and passthrough options still apply,
+// since members-only or age-restricted formats need them.
+func listURLFormats(url string, config *Config) error {
+	args := []string{"--list-formats"}
+	if config.CookiesFrom != "" {
+		args = append(args, "--cookies-from-browser", config.CookiesFrom)
+	}
+	args = append(args, config.ExtraArgs...)
+	args = append(args, url)
 
-	cmdArgs := buildYTDLPArgs(url, codecPref, destinationPath, cookiesFrom, socm)
-	cmd := exec.Command("yt-dlp", cmdArgs...)
+	cmd := exec.Command("yt-dlp", args...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
 
-	if err := cmd.Run(); err != nil {
-		fmt.Printf("%sFailed to download: %s (exit code: %v)%s\n", colorRed, url, err, colorReset)
-		failedURLsChan <- url
-	} else {
-		fmt.Printf("%sCompleted download: %s%s\n", colorGreen, url, colorReset)
+// runYTDLP runs yt-dlp for a URL, retrying up to config.Retries times when a
+// download fails after it had already started transferring data. When ctx is
+// done the running yt-dlp process group is terminated.
+func runYTDLP(ctx context.Context, url string, config *Config, stdout, stderr io.Writer, filesPath string) error {
+	cmdArgs := buildYTDLPArgs(url, config)
+	if filesPath != "" {
+		// Record final file paths; unlike --print this doesn't silence yt-dlp.
+		// Inserted before the URL, which buildYTDLPArgs always puts last.
+		cmdArgs = slices.Insert(cmdArgs, len(cmdArgs)-1, "--print-to-file", "after_move:filepath", filesPath)
+	}
+
+	// A terminal is handed to yt-dlp as is so it and aria2c keep their
+	// progress bars. Progress is then detected by yt-dlp reaching the
+	// download step, which it records in startedPath.
+	startedPath := ""
+	if f, ok := stdout.(*os.File); ok && term.IsTerminal(f) {
+		marker, err := os.CreateTemp("", "ytmax-started-*.txt")
+		if err == nil {
+			startedPath = marker.Name()
+			marker.Close()
+			defer os.Remove(startedPath)
+			cmdArgs = slices.Insert(cmdArgs, len(cmdArgs)-1, "--print-to-file", "before_dl:%(id)s", startedPath)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		var sawProgress atomic.Bool
+		var errOutput bytes.Buffer
+		cmd := exec.CommandContext(ctx, "yt-dlp", cmdArgs...)
+		if startedPath != "" {
+			if err := os.Truncate(startedPath, 0); err != nil {
+				return err
+			}
+			cmd.Stdout = stdout
+			// stderr is still copied, as the live stream check needs yt-dlp's error text.
+			cmd.Stderr = io.MultiWriter(stderr, &errOutput)
+		} else {
+			cmd.Stdout = progressWriter{out: stdout, sawProgress: &sawProgress}
+			cmd.Stderr = progressWriter{out: io.MultiWriter(stderr, &errOutput), sawProgress: &sawProgress}
+		}
+		if ctx.Done() != nil {
+			// Own process group so cancellation also reaches aria2c/ffmpeg.
+			cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+			cmd.Cancel = func() error {
+				return syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+			}
+			cmd.WaitDelay = killGracePeriod
+		}
+
+		err := cmd.Run()
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !config.LiveFromStart && looksLikeLiveStream(errOutput.String()) {
+			return fmt.Errorf("%w (%v): use -live-from-start, or retry once the stream has ended", errLiveStream, err)
+		}
+
+		if startedPath != "" {
+			if info, statErr := os.Stat(startedPath); statErr == nil && info.Size() > 0 {
+				sawProgress.Store(true)
+			}
+		}
+
+		// Failures before any progress (bad URL, unavailable format, ...) won't fix themselves.
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || !sawProgress.Load() || attempt >= config.Retries {
+			return err
+		}
+
+		if !config.SummaryProgress {
+			fmt.Printf("%sDownload interrupted: %s. Retrying in %s (%d/%d)...%s\n", term.Yellow, url, retryDelay, attempt+1, config.Retries, term.Reset)
+		}
+		select {
+		case <-time.After(retryDelay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
 	}
 }
 
-// batchDownload handles downloading multiple URLs concurrently.
-func batchDownload(urls []string, codecPref, destinationPath, cookiesFrom string, socm bool, parallel int) {
+// Download states shown by -summary-progress.
+const (
+	stateQueued      = "queued"
+	stateDownloading = "downloading"
+	stateDone        = "done"
+	stateFailed      = "failed"
 
-	// Sanitize and deduplicate URLs
-	cleanURLs := sanitizeAndDeduplicateURLs(urls)
-	if len(cleanURLs) == 0 {
-		fatalf("no valid URLs provided")
+	renderInterval = 500 * time.Millisecond
+)
+
+// statusTracker keeps a thread-safe state per URL and redraws them as a
+// compact block of status lines for -summary-progress. When stdout is not a
+// terminal it prints one line per state change instead.
+type statusTracker struct {
+	mu      sync.Mutex
+	urls    []string
+	states  map[string]string
+	details map[string]string
+	drawn   int
+	inPlace bool
+}
+
+// reset starts a new round with every URL queued.
+func (t *statusTracker) reset(urls []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.urls = urls
+	t.states = make(map[string]string, len(urls))
+	t.details = make(map[string]string)
+	for _, url := range urls {
+		t.states[url] = stateQueued
 	}
+	t.drawn = 0
+}
 
-	if len(cleanURLs) != len(urls) {
-		fmt.Printf("Processing %s%d%s valid URLs (filtered from %s%d%s)\n", colorCyan, len(cleanURLs), colorReset, colorCyan, len(urls), colorReset)
+func (t *statusTracker) set(url, state, detail string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	changed := t.states[url] != state
+	t.states[url] = state
+	t.details[url] = detail
+	if changed && !t.inPlace {
+		fmt.Println(t.line(url))
+	}
+}
+
+// line formats the status line of url. The caller holds t.mu.
+func (t *statusTracker) line(url string) string {
+	state := t.states[url]
+	color := term.Cyan
+	switch state {
+	case stateDone:
+		color = term.Green
+	case stateFailed:
+		color = term.Red
+	case stateQueued:
+		color = term.Reset
+	}
+	line := fmt.Sprintf("%s%-11s%s %s", color, state, term.Reset, url)
+	if detail := t.details[url]; detail != "" {
+		line += fmt.Sprintf(" %s(%s)%s", term.Red, detail, term.Reset)
 	}
+	return line
+}
 
-	// Setup signal handling for graceful shutdown
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+// render redraws the status block in place.
+func (t *statusTracker) render() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.drawn > 0 {
+		fmt.Printf("\033[%dA", t.drawn) // Move back up to the top of the block.
+	}
+	for _, url := range t.urls {
+		fmt.Printf("\033[2K%s\n", t.line(url))
+	}
+	t.drawn = len(t.urls)
+}
+
+// newBlock makes the next render draw a fresh block below whatever was
+// printed since the last one.
+func (t *statusTracker) newBlock() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.drawn = 0
+}
+
+// startRenderer redraws the block periodically until the returned stop
+// function is called, which draws the final state. Without a terminal,
+// set prints the changes and there is nothing to redraw.
+func (t *statusTracker) startRenderer() (stop func()) {
+	if !t.inPlace {
+		return func() {}
+	}
+
+	quit := make(chan struct{})
+	finished := make(chan struct{})
+
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(renderInterval)
+		defer ticker.Stop()
+		for {
+			t.render()
+			select {
+			case <-quit:
+				t.render()
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		close(quit)
+		<-finished
+	}
+}
+
+// lastLine returns the last non-empty line of output.
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// downloadResult is the outcome of one URL in batch mode.
+type downloadResult struct {
+	URL     string   `json:"url"`
+	Success bool     `json:"success"`
+	Files   []string `json:"files,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// downloadURL executes yt-dlp for a single URL in a goroutine.
+func downloadURL(ctx context.Context, url string, config *Config, tracker *statusTracker, wg *sync.WaitGroup, sem chan struct{}, resultsChan chan<- downloadResult) {
+	defer wg.Done()
+	defer func() { <-sem }() // Release semaphore slot.
+
+	// stderr is always captured so a failure reason can be reported.
+	var stderr bytes.Buffer
+	var stdoutWriter, stderrWriter io.Writer = os.Stdout, io.MultiWriter(os.Stderr, &stderr)
+	if tracker != nil {
+		// Summary mode: hide yt-dlp's output entirely.
+		stdoutWriter, stderrWriter = io.Discard, &stderr
+		tracker.set(url, stateDownloading, "")
+	} else {
+		fmt.Printf("Starting download: %s%s%s\n", term.Cyan, url, term.Reset)
+	}
+
+	// For the report, yt-dlp writes the final path of every file it produces here.
+	filesPath := ""
+	if config.ReportFile != "" {
+		if f, err := os.CreateTemp("", "ytmax-files-*.txt"); err == nil {
+			filesPath = f.Name()
+			f.Close()
+			defer os.Remove(filesPath)
+		}
+	}
+
+	batchCtx := ctx
+	if config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.Timeout)
+		defer cancel()
+	}
+
+	result := downloadResult{URL: url}
+	err := runYTDLP(ctx, url, config, stdoutWriter, stderrWriter, filesPath)
+	if err != nil && errors.Is(batchCtx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("stopped: batch time limit of %s reached", config.TotalTimeout)
+	} else if errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %s", config.Timeout)
+	} else if errors.Is(err, context.Canceled) {
+		err = errors.New("interrupted")
+	}
+	if filesPath != "" {
+		if data, readErr := os.ReadFile(filesPath); readErr == nil {
+			for _, line := range strings.Split(string(data), "\n") {
+				if line = strings.TrimSpace(line); line != "" {
+					result.Files = append(result.Files, line)
+				}
+			}
+		}
+	}
+
+	if err != nil {
+		result.Error = lastLine(stderr.String())
+		if result.Error == "" || ctx.Err() != nil || errors.Is(err, errLiveStream) {
+			result.Error = err.Error()
+		}
+		if tracker != nil {
+			tracker.set(url, stateFailed, result.Error)
+		} else {
+			fmt.Printf("%sFailed to download: %s (exit code: %v)%s\n", term.Red, url, err, term.Reset)
+		}
+	} else {
+		result.Success = true
+		if tracker != nil {
+			tracker.set(url, stateDone, "")
+		} else {
+			fmt.Printf("%sCompleted download: %s%s\n", term.Green, url, term.Reset)
+		}
+	}
+
+	resultsChan <- result
+}
+
+// runBatchRound downloads urls concurrently and returns their results.
+// Cancelling ctx stops in-flight downloads and skips the ones not yet started;
+// interrupted reports whether that happened during the round.
+func runBatchRound(ctx context.Context, urls []string, config *Config, parallel int, tracker *statusTracker) (results []downloadResult, interrupted bool) {
+	var stopRender func()
+	if tracker != nil {
+		tracker.reset(urls)
+		stopRender = tracker.startRenderer()
+		defer func() { stopRender() }()
+	}
 
 	var wg sync.WaitGroup
 	sem := make(chan struct{}, parallel)
-	failedURLsChan := make(chan string, len(cleanURLs))
+	resultsChan := make(chan downloadResult, len(urls))
 	done := make(chan bool, 1)
 
 	// Launch downloads
 	go func() {
-		for _, url := range cleanURLs {
+		for _, url := range urls {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+			}
+			if ctx.Err() != nil {
+				break
+			}
 			wg.Add(1)
-			sem <- struct{}{}
-			go downloadURL(url, codecPref, destinationPath, cookiesFrom, socm, &wg, sem, failedURLsChan)
+			go downloadURL(ctx, url, config, tracker, &wg, sem, resultsChan)
 		}
 		wg.Wait()
 		done <- true
@@ -198,51 +935,232 @@ func batchDownload(urls []string, codecPref, destinationPath, cookiesFrom string
 	select {
 	case <-done:
 		// Downloads completed normally
-	case <-sigChan:
-		fmt.Printf("\n%sReceived termination signal. Waiting for active downloads to complete...%s\n", colorYellow, colorReset)
+	case <-ctx.Done():
+		if tracker != nil {
+			// Finish the block before printing below it; the final states
+			// are drawn in a new block under the message.
+			stopRender()
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			fmt.Printf("\n%sBatch time limit reached. Stopping active downloads...%s\n", term.Yellow, term.Reset)
+		} else {
+			fmt.Printf("\n%sReceived termination signal. Stopping active downloads...%s\n", term.Yellow, term.Reset)
+		}
+		if tracker != nil {
+			tracker.newBlock()
+			stopRender = tracker.startRenderer()
+		}
+		interrupted = true
 		<-done
 	}
 
-	close(failedURLsChan)
+	close(resultsChan)
+
+	for result := range resultsChan {
+		results = append(results, result)
+	}
+	return results, interrupted
+}
+
+// failedOf returns the URLs of the unsuccessful results.
+func failedOf(results []downloadResult) []string {
+	var failed []string
+	for _, result := range results {
+		if !result.Success {
+			failed = append(failed, result.URL)
+		}
+	}
+	return failed
+}
 
-	var failedURLs []string
-	for url := range failedURLsChan {
-		failedURLs = append(failedURLs, url)
+// writeReport saves batch results as JSON or CSV depending on the file extension.
+func writeReport(path string, results []downloadResult) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
 	}
+	defer f.Close()
 
-	if len(failedURLs) > 0 {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(results)
+	}
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"url", "status", "files", "error"})
+	for _, result := range results {
+		status := "failed"
+		if result.Success {
+			status = "ok"
+		}
+		w.Write([]string{result.URL, status, strings.Join(result.Files, ";"), result.Error})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// batchDownload handles downloading multiple URLs concurrently.
+func batchDownload(urls []string, config *Config, parallel int) {
+
+	// Sanitize and deduplicate URLs
+	cleanURLs, rejected := sanitizeAndDeduplicateURLs(urls)
+	if len(cleanURLs) == 0 {
+		term.Fatalf("no valid URLs provided")
+	}
+
+	if len(cleanURLs) != len(urls) {
+		fmt.Printf("Processing %s%d%s valid URLs (filtered from %s%d%s)\n", term.Cyan, len(cleanURLs), term.Reset, term.Cyan, len(urls), term.Reset)
+	}
+
+	// Cancel every in-flight yt-dlp on SIGINT/SIGTERM.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if config.TotalTimeout > 0 {
+		// Bound the whole batch, retries included; expiry stops it like Ctrl-C.
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.TotalTimeout)
+		defer cancel()
+	}
+
+	var tracker *statusTracker
+	if config.SummaryProgress {
+		tracker = &statusTracker{inPlace: term.IsTerminal(os.Stdout)}
+	}
+
+	// Latest result per URL; retries overwrite earlier failures.
+	resultsByURL := make(map[string]downloadResult, len(cleanURLs))
+	record := func(results []downloadResult) {
+		for _, result := range results {
+			resultsByURL[result.URL] = result
+		}
+	}
+
+	results, interrupted := runBatchRound(ctx, cleanURLs, config, parallel, tracker)
+	record(results)
+	failedURLs := failedOf(results)
+	total := len(cleanURLs) + len(rejected)
+
+	// Give failed URLs more chances once the first pass is done.
+	for round := 1; round <= config.RetryFailed && len(failedURLs) > 0 && !interrupted; round++ {
+		fmt.Printf("\n%sRetrying %d failed download(s) (round %d/%d)...%s\n", term.Yellow, len(failedURLs), round, config.RetryFailed, term.Reset)
+		results, interrupted = runBatchRound(ctx, failedURLs, config, parallel, tracker)
+		record(results)
+		failedURLs = failedOf(results)
+	}
+
+	if config.ReportFile != "" {
+		report := slices.Clone(rejected)
+		for _, url := range cleanURLs {
+			if result, ok := resultsByURL[url]; ok {
+				report = append(report, result)
+			}
+		}
+		if err := writeReport(config.ReportFile, report); err != nil {
+			fmt.Printf("%sWarning: could not write report '%s': %v%s\n", term.Yellow, config.ReportFile, err, term.Reset)
+		} else {
+			fmt.Printf("Report written to %s%s%s\n", term.Cyan, config.ReportFile, term.Reset)
+		}
+	}
+
+	if interrupted {
+		var completed int
+		var failed []string
+		for _, url := range cleanURLs {
+			if result, ok := resultsByURL[url]; ok && result.Success {
+				completed++
+			} else if ok {
+				failed = append(failed, url)
+			}
+		}
+		timedOut := errors.Is(ctx.Err(), context.DeadlineExceeded)
+		if timedOut {
+			fmt.Printf("\n--- Summary (time limit of %s reached) ---\n", config.TotalTimeout)
+		} else {
+			fmt.Printf("\n--- Summary (interrupted) ---\n")
+		}
+		fmt.Printf("%s%d completed, %d failed or stopped, %d not started.%s\n", term.Yellow, completed, len(failed), len(cleanURLs)-completed-len(failed), term.Reset)
+		for _, url := range failed {
+			fmt.Printf("  - %s%s%s\n", term.Red, url, term.Reset)
+		}
+		if timedOut {
+			os.Exit(1)
+		}
+		os.Exit(130)
+	}
+
+	if len(failedURLs) > 0 || len(rejected) > 0 {
 		fmt.Printf("\n--- Summary ---\n")
-		fmt.Printf("%s%d/%d downloads failed.%s\n", colorRed, len(failedURLs), len(cleanURLs), colorReset)
+		fmt.Printf("%s%d/%d downloads failed.%s\n", term.Red, len(failedURLs)+len(rejected), total, term.Reset)
 		fmt.Println("Failed URLs:")
+		for _, result := range rejected {
+			fmt.Printf("  - %s%s (%s)%s\n", term.Red, result.URL, result.Error, term.Reset)
+		}
 		for _, url := range failedURLs {
-			fmt.Printf("  - %s%s%s\n", colorRed, url, colorReset)
+			fmt.Printf("  - %s%s%s\n", term.Red, url, term.Reset)
 		}
 		os.Exit(1)
 	} else {
 		fmt.Printf("\n--- Summary ---\n")
-		fmt.Printf("%sAll %d downloads completed successfully.%s\n", colorGreen, len(cleanURLs), colorReset)
+		fmt.Printf("%sAll %d downloads completed successfully.%s\n", term.Green, len(cleanURLs), term.Reset)
 	}
 }
 
 func main() {
 	// Define command-line flags.
+	config := &Config{}
 	var (
-		codecPref       string
-		destinationPath string
-		cookiesFrom     string
-		socm            bool
-		parallel        int
+		parallel    int
+		inputFile   string
+		colorMode   string
+		showVersion bool
+		listFormats bool
 	)
 
-	flag.StringVar(&codecPref, "codec", codecAV1, "Preferred video codec (av1 or vp9). Ignored if -socm is used.")
-	flag.StringVar(&destinationPath, "d", "", "Download destination. Can be a directory or a full file path.")
-	flag.StringVar(&cookiesFrom, "cookies-from", "", "Load cookies from the specified browser (e.g., firefox, chrome).")
-	flag.BoolVar(&socm, "socm", false, "Optimize for social media compatibility (MP4, H.264/AAC).")
+	flag.StringVar(&config.CodecPref, "codec", ytdlp.CodecAV1, "Preferred video codec ("+strings.Join(ytdlp.Codecs, ", ")+"). Ignored if -socm is used.")
+	flag.StringVar(&config.DestinationPath, "d", "", "Download destination. Can be a directory or a full file path.")
+	flag.IntVar(&config.MaxHeight, "max-res", defaultMaxHeight, "Maximum video height in pixels (e.g., 1080). Ignored if -socm or -audio is used.")
+	flag.StringVar(&config.Format, "format", "", "Custom yt-dlp format selector (e.g., 'bv*[vcodec^=avc]+ba/b'); replaces -max-res. -codec still sorts the matches.")
+	flag.StringVar(&config.OutputTemplate, "output-template", defaultFilenamePattern, "yt-dlp filename template, joined to -d when it is a directory.")
+	flag.StringVar(&config.CookiesFrom, "cookies-from", "", "Load cookies from a browser, optionally a profile (e.g., firefox, chrome, firefox:work).")
+	flag.StringVar(&config.CookiesKeyring, "cookies-keyring", "", "Keyring used to decrypt Chromium cookies (e.g., gnomekeyring, kwallet).")
+	flag.BoolVar(&config.Socm, "socm", false, "Optimize for social media compatibility (MP4, H.264/AAC).")
+	flag.BoolVar(&config.Audio, "audio", false, "Download audio only. Cannot be combined with -socm.")
+	flag.StringVar(&config.AudioFormat, "audio-format", defaultAudioFormat, "Audio format for -audio ("+strings.Join(audioFormats, ", ")+").")
+	flag.StringVar(&config.SubLangs, "subs", "", "Download subtitles for these comma-separated languages (e.g., en,es).")
+	flag.BoolVar(&config.AutoSubs, "auto-subs", false, "Fall back to auto-generated captions when no manual subtitles exist.")
+	flag.BoolVar(&config.EmbedSubs, "embed-subs", false, "Embed subtitles into the video file (requires ffmpeg).")
+	flag.BoolVar(&config.SponsorBlock, "sponsorblock", false, "Remove SponsorBlock segments from the video (requires ffmpeg).")
+	flag.StringVar(&config.SponsorBlockCats, "sponsorblock-cats", "sponsor", "Comma-separated SponsorBlock categories to remove (e.g., sponsor,intro,outro,selfpromo).")
+	flag.StringVar(&config.PlaylistItems, "playlist-items", "", "Only download these playlist entries (e.g., 1-5,8). Files get a playlist index prefix.")
+	flag.BoolVar(&config.LiveFromStart, "live-from-start", false, "Download live streams from their beginning instead of the current point.")
+	flag.BoolVar(&config.NoPlaylist, "no-playlist", false, "Download only the video when the URL also references a playlist.")
+	flag.BoolVar(&config.EmbedThumbnail, "embed-thumbnail", false, "Embed the video thumbnail as cover art (requires ffmpeg).")
+	flag.BoolVar(&config.EmbedMetadata, "embed-metadata", false, "Embed title, uploader, date and description metadata (requires ffmpeg).")
+	flag.BoolVar(&config.SplitChapters, "split-chapters", false, "Also save each chapter as a separate file (requires ffmpeg).")
+	flag.BoolVar(&config.WriteInfoJSON, "write-info-json", false, "Save the video's metadata as a .info.json file next to it.")
+	flag.BoolVar(&config.KeepMtime, "mtime", false, "Set each file's modification time to the video's upload time instead of the download time.")
+	flag.BoolVar(&config.WriteDescription, "write-description", false, "Save the video description as a .description file next to it.")
+	flag.StringVar(&config.ArchiveFile, "archive", "", "Record downloaded video IDs in this file and skip ones already listed.")
+	flag.BoolVar(&config.NoAria2c, "no-aria2c", false, "Use yt-dlp's built-in downloader instead of aria2c.")
+	flag.IntVar(&config.Connections, "connections", defaultConnections, "aria2c connections per server (1-16); lower it on constrained links.")
+	flag.IntVar(&config.Splits, "splits", defaultSplits, "Number of pieces aria2c downloads each file in.")
+	flag.StringVar(&config.LimitRate, "limit-rate", "", "Maximum download speed per video (e.g., 500K, 2M).")
+	flag.IntVar(&config.Retries, "retries", defaultRetries, "Retry a download this many times if it fails after it has started.")
+	flag.IntVar(&config.RetryFailed, "retry-failed", 0, "In batch mode, re-run failed URLs up to this many extra rounds.")
+	flag.BoolVar(&config.SummaryProgress, "summary-progress", false, "In batch mode, show one status line per URL instead of yt-dlp's output.")
+	flag.DurationVar(&config.Timeout, "timeout", 0, "In batch mode, give up on a URL after this long (e.g., 30m). 0 means no limit.")
+	flag.DurationVar(&config.TotalTimeout, "total-timeout", 0, "In batch mode, stop the whole batch after this long (e.g., 2h), including retries. 0 means no limit.")
+	flag.StringVar(&config.ReportFile, "report", "", "In batch mode, write each URL's outcome and files to a .csv or .json report.")
+	flag.BoolVar(&listFormats, "list-formats", false, "List the available formats for each URL and exit without downloading.")
+	flag.StringVar(&inputFile, "i", "", "Read URLs from a file (one per line or comma-separated); use - for stdin.")
 	flag.IntVar(&parallel, "p", 4, "Number of parallel downloads for batch mode.")
+	flag.StringVar(&colorMode, "color", term.ColorAuto, "Colorize output: auto, always or never.")
+	flag.BoolVar(&showVersion, "version", false, "Print version information and exit.")
 
 	flag.Usage = func() {
 		out := flag.CommandLine.Output()
-		fmt.Fprintf(out, "Usage: ytmax [options] URL [URL...]\n\n")
+		fmt.Fprintf(out, "Usage: ytmax [options] URL [URL...] [-- yt-dlp options]\n\n")
 		fmt.Fprintf(out, "A wrapper for yt-dlp to download single videos or batches with optimized settings.\n")
 		fmt.Fprintf(out, "Automatically detects batch mode when multiple URLs are provided.\n\n")
 		fmt.Fprintf(out, "Options:\n")
@@ -253,43 +1171,222 @@ func main() {
 		fmt.Fprintf(out, "  Batch download:\n")
 		fmt.Fprintf(out, "    ytmax -d /videos -p 6 \"URL1\" \"URL2\" \"URL3\"\n")
 		fmt.Fprintf(out, "    ytmax --cookies-from firefox \"URL1\" \"URL2\"\n")
+		fmt.Fprintf(out, "    ytmax -d /videos -i urls.txt\n")
+		fmt.Fprintf(out, "  Audio only:\n")
+		fmt.Fprintf(out, "    ytmax -audio -audio-format mp3 https://youtu.be/VIDEO_ID\n")
+		fmt.Fprintf(out, "  Extra yt-dlp options:\n")
+		fmt.Fprintf(out, "    ytmax https://youtu.be/VIDEO_ID -- --limit-rate 2M\n")
 	}
 
-	flag.Parse()
+	ownArgs, passthrough := splitPassthrough(os.Args[1:])
+	flag.CommandLine.Parse(ownArgs)
+	config.ExtraArgs = passthrough
+
+	if showVersion {
+		fmt.Println(version.String("ytmax"))
+		return
+	}
+
+	if err := term.SetColorMode(colorMode); err != nil {
+		term.Fatalf("-color: %v", err)
+	}
+
+	urls := flag.Args()
+	if inputFile != "" {
+		fileURLs, err := readURLList(inputFile)
+		if err != nil {
+			term.Fatalf("reading URLs from '%s': %v", inputFile, err)
+		}
+		urls = append(urls, fileURLs...)
+	}
 
 	// Check for URL arguments.
-	if flag.NArg() < 1 {
+	if len(urls) < 1 {
 		flag.Usage()
 		os.Exit(1)
 	}
 
 	if parallel < 1 {
-		fatalf("number of parallel downloads (-p) must be at least 1")
+		term.Fatalf("number of parallel downloads (-p) must be at least 1")
+	}
+
+	if config.LimitRate != "" && !rateLimitRe.MatchString(config.LimitRate) {
+		term.Fatalf("invalid rate limit '%s' (expected e.g. 500K or 2M)", config.LimitRate)
+	}
+
+	if config.Connections < 1 || config.Connections > 16 {
+		term.Fatalf("connections (-connections) must be between 1 and 16")
+	}
+	if config.Splits < 1 {
+		term.Fatalf("splits (-splits) must be at least 1")
+	}
+
+	if config.ReportFile != "" {
+		ext := strings.ToLower(filepath.Ext(config.ReportFile))
+		if ext != ".csv" && ext != ".json" {
+			term.Fatalf("report file (-report) must end in .csv or .json")
+		}
+	}
+
+	if config.RetryFailed < 0 {
+		term.Fatalf("number of retry rounds (-retry-failed) cannot be negative")
+	}
+
+	if config.Timeout < 0 {
+		term.Fatalf("timeout (-timeout) cannot be negative")
+	}
+
+	if config.TotalTimeout < 0 {
+		term.Fatalf("total timeout (-total-timeout) cannot be negative")
+	}
+
+	if config.Retries < 0 {
+		term.Fatalf("number of retries (-retries) cannot be negative")
+	}
+
+	if config.MaxHeight < 1 {
+		term.Fatalf("maximum resolution (-max-res) must be a positive height, e.g. 1080")
+	}
+
+	if strings.TrimSpace(config.OutputTemplate) == "" {
+		term.Fatalf("output template (-output-template) cannot be empty")
+	}
+	if !strings.Contains(config.OutputTemplate, "%(ext)s") {
+		fmt.Printf("%sWarning: output template has no %%(ext)s; files may be saved without an extension%s\n", term.Yellow, term.Reset)
+	}
+
+	if _, err := ytdlp.CodecSortString(config.CodecPref); err != nil && !config.Socm && !config.Audio {
+		term.Fatalf("%v", err)
+	}
+
+	if config.Audio && config.Socm {
+		term.Fatalf("-audio and -socm cannot be used together")
+	}
+
+	if config.Audio {
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "max-res" {
+				fmt.Fprintf(os.Stderr, "%sNote: -max-res is ignored with -audio%s\n", term.Yellow, term.Reset)
+			}
+		})
+	}
+
+	if config.Format != "" && (config.Audio || config.Socm) {
+		term.Fatalf("-format cannot be combined with -audio or -socm, which pick their own formats")
+	}
+
+	if config.Audio && !slices.Contains(audioFormats, config.AudioFormat) {
+		term.Fatalf("invalid audio format '%s'. Use one of: %s", config.AudioFormat, strings.Join(audioFormats, ", "))
+	}
+
+	if config.SubLangs != "" {
+		langs, err := normalizeSubLangs(config.SubLangs)
+		if err != nil {
+			term.Fatalf("%v", err)
+		}
+		config.SubLangs = langs
+	}
+
+	if config.PlaylistItems != "" {
+		if config.NoPlaylist {
+			term.Fatalf("-playlist-items and -no-playlist cannot be used together")
+		}
+		config.PlaylistItems = strings.ReplaceAll(config.PlaylistItems, " ", "")
+		if !playlistItemsRe.MatchString(config.PlaylistItems) {
+			term.Fatalf("invalid -playlist-items spec '%s' (expected e.g. 1-5,8)", config.PlaylistItems)
+		}
+	}
+
+	if config.CookiesKeyring != "" && config.CookiesFrom == "" {
+		term.Fatalf("-cookies-keyring requires -cookies-from")
+	}
+	if config.CookiesFrom != "" {
+		cookies, err := buildCookiesFromBrowser(config.CookiesFrom, config.CookiesKeyring)
+		if err != nil {
+			term.Fatalf("%v", err)
+		}
+		config.CookiesFrom = cookies
+	}
+
+	if config.ArchiveFile != "" {
+		archivePath, err := filepath.Abs(config.ArchiveFile)
+		if err != nil {
+			term.Fatalf("resolving archive path '%s': %v", config.ArchiveFile, err)
+		}
+		if info, err := os.Stat(filepath.Dir(archivePath)); err != nil || !info.IsDir() {
+			term.Fatalf("archive directory does not exist: %s", filepath.Dir(archivePath))
+		}
+		// Absolute so batch downloads agree on the file regardless of -d.
+		config.ArchiveFile = archivePath
+	}
+
+	if config.SponsorBlock {
+		cats, err := normalizeSponsorBlockCats(config.SponsorBlockCats)
+		if err != nil {
+			term.Fatalf("%v", err)
+		}
+		config.SponsorBlockCats = cats
+	} else {
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "sponsorblock-cats" {
+				term.Fatalf("-sponsorblock-cats requires -sponsorblock")
+			}
+		})
+	}
+
+	if listFormats {
+		checkDependencies("yt-dlp")
+		validURLs, rejected := sanitizeAndDeduplicateURLs(urls)
+		failed := len(rejected) > 0
+		for _, url := range validURLs {
+			if err := listURLFormats(url, config); err != nil {
+				failed = true
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+		return
 	}
 
 	// Check dependencies early
-	checkDependencies("yt-dlp", "aria2c")
+	deps := []string{"yt-dlp"}
+	if !config.NoAria2c {
+		deps = append(deps, "aria2c")
+	}
+	if config.Audio || config.EmbedSubs || config.SponsorBlock || config.EmbedThumbnail || config.EmbedMetadata || config.SplitChapters {
+		// Audio extraction, embedding, segment removal and chapter splitting are done by ffmpeg.
+		deps = append(deps, "ffmpeg")
+	}
+	checkDependencies(deps...)
 
-	urls := flag.Args()
+	if config.DestinationPath != "" {
+		path, isDir, err := resolveDestination(config.DestinationPath)
+		if err != nil {
+			term.Fatalf("%v", err)
+		}
+		if !isDir && len(urls) > 1 {
+			term.Fatalf("destination '%s' is a single file path but %d URLs were given; pass a directory instead", config.DestinationPath, len(urls))
+		}
+		config.DestinationPath, config.DestIsDir = path, isDir
+	}
 
 	// Detect batch mode vs single download.
 	if len(urls) == 1 {
 		// Single download mode.
 		url := strings.TrimSpace(urls[0])
-		if !validateURL(url) {
-			fatalf("invalid URL provided: %s", url)
+		if err := validateURL(url); err != nil {
+			term.Fatalf("invalid URL provided: %s (%v)", url, err)
 		}
 
-		cmdArgs := buildYTDLPArgs(url, codecPref, destinationPath, cookiesFrom, socm)
-		cmd := exec.Command("yt-dlp", cmdArgs...)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-
-		if err := cmd.Run(); err != nil {
+		if err := runYTDLP(context.Background(), url, config, os.Stdout, os.Stderr, ""); err != nil {
+			if errors.Is(err, errLiveStream) {
+				fmt.Fprintf(os.Stderr, "%s%v%s\n", term.Yellow, err, term.Reset)
+			}
 			os.Exit(1)
 		}
 	} else {
 		// Batch download mode.
-		batchDownload(urls, codecPref, destinationPath, cookiesFrom, socm, parallel)
+		batchDownload(urls, config, parallel)
 	}
 }
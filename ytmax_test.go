@@ -0,0 +1,155 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// argValue returns the argument following flag in args, or "" when flag is absent.
+func argValue(args []string, flag string) string {
+	if i := slices.Index(args, flag); i >= 0 && i+1 < len(args) {
+		return args[i+1]
+	}
+	return ""
+}
+
+func testConfig() *Config {
+	return &Config{
+		CodecPref:      "av1",
+		MaxHeight:      defaultMaxHeight,
+		OutputTemplate: defaultFilenamePattern,
+		LimitRate:      "2M",
+	}
+}
+
+func TestBuildAria2cDownloaderArgs(t *testing.T) {
+	config := testConfig()
+	if got := buildAria2cDownloaderArgs(config); !strings.Contains(got, "--max-download-limit=2M") {
+		t.Errorf("buildAria2cDownloaderArgs() = %q, want it to contain --max-download-limit=2M", got)
+	}
+
+	config.LimitRate = ""
+	if got := buildAria2cDownloaderArgs(config); strings.Contains(got, "--max-download-limit") {
+		t.Errorf("buildAria2cDownloaderArgs() without -limit-rate = %q, want no --max-download-limit", got)
+	}
+}
+
+func TestBuildYTDLPArgsRateLimit(t *testing.T) {
+	const url = "https://www.youtube.com/watch?v=dQw4w9WgXcQ"
+
+	t.Run("aria2c", func(t *testing.T) {
+		args := buildYTDLPArgs(url, testConfig())
+		if got := argValue(args, "--external-downloader"); got != "aria2c" {
+			t.Errorf("--external-downloader = %q, want aria2c", got)
+		}
+		if got := argValue(args, "--external-downloader-args"); !strings.Contains(got, "--max-download-limit=2M") {
+			t.Errorf("--external-downloader-args = %q, want --max-download-limit=2M", got)
+		}
+		if slices.Contains(args, "--limit-rate") {
+			t.Errorf("args = %q, want no --limit-rate when aria2c enforces the limit", args)
+		}
+	})
+
+	t.Run("no-aria2c", func(t *testing.T) {
+		config := testConfig()
+		config.NoAria2c = true
+		args := buildYTDLPArgs(url, config)
+		if got := argValue(args, "--limit-rate"); got != "2M" {
+			t.Errorf("--limit-rate = %q, want 2M", got)
+		}
+		if slices.Contains(args, "--external-downloader") {
+			t.Errorf("args = %q, want no --external-downloader with -no-aria2c", args)
+		}
+	})
+}
+
+func TestValidateURL(t *testing.T) {
+	tests := []struct {
+		url   string
+		valid bool
+	}{
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ", true},
+		{"https://youtu.be/dQw4w9WgXcQ", true},
+		{"https://vimeo.com/76979871", true},
+		{"http://[::1]:8080/video.mp4", true},
+		{"http://192.168.1.10/clip.mp4", true},
+		{"http://nas/clip.mp4", true},
+		{"ftp://example.com/clip.mp4", false},
+		{"example.com/clip.mp4", false},
+		{"https:///clip.mp4", false},
+		{"https://youtu.be/", false},
+		{"https://www.youtube.com/watch?list=PL123", false},
+	}
+	for _, tt := range tests {
+		if err := validateURL(tt.url); (err == nil) != tt.valid {
+			t.Errorf("validateURL(%q) = %v, want valid %v", tt.url, err, tt.valid)
+		}
+	}
+}
+
+func TestResolveDestination(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "existing"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		dest    string
+		want    string
+		isDir   bool
+		created bool   // the directory must exist afterwards
+		wantErr string // substring of the expected error
+	}{
+		{name: "existing dir", dest: "existing", want: "existing", isDir: true, created: true},
+		{name: "missing dir without extension", dest: "new", want: "new", isDir: true, created: true},
+		{name: "trailing separator", dest: "season.1/", want: "season.1", isDir: true, created: true},
+		{name: "media file", dest: "existing/clip.mp4", want: "existing/clip.mp4"},
+		{name: "media file in missing dir", dest: "missing/clip.mp4", wantErr: "does not exist"},
+		{name: "dotted dir without separator", dest: "season.2", wantErr: "end -d with '/'"},
+		{name: "templated dir", dest: "%(uploader)s/", want: "%(uploader)s", isDir: true},
+		{name: "templated file", dest: "existing/%(title)s.%(ext)s", want: "existing/%(title)s.%(ext)s"},
+		{name: "file under templated dir", dest: "%(uploader)s/%(title)s.%(ext)s", want: "%(uploader)s/%(title)s.%(ext)s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, isDir, err := resolveDestination(filepath.Join(dir, tt.dest) + trailingSlash(tt.dest))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("resolveDestination(%q) error = %v, want it to mention %q", tt.dest, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveDestination(%q): %v", tt.dest, err)
+			}
+			if want := filepath.Join(dir, tt.want); path != want || isDir != tt.isDir {
+				t.Errorf("resolveDestination(%q) = %q, %v, want %q, %v", tt.dest, path, isDir, want, tt.isDir)
+			}
+			if info, err := os.Stat(path); tt.created && (err != nil || !info.IsDir()) {
+				t.Errorf("directory %q was not created", path)
+			}
+		})
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if strings.Contains(e.Name(), "%(") {
+			t.Errorf("templated directory %q was created, want it left to yt-dlp", e.Name())
+		}
+	}
+}
+
+// trailingSlash returns "/" when dest ends with one, which filepath.Join drops.
+func trailingSlash(dest string) string {
+	if strings.HasSuffix(dest, "/") {
+		return "/"
+	}
+	return ""
+}